@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/joho/godotenv"
+
+	"github.com/iperez/new-expenses-go/internal/config"
+	"github.com/iperez/new-expenses-go/internal/database"
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/internal/service"
+)
+
+// Merges categories that only differ in case, accents or spacing, then creates the unique index preventing new ones.
+func main() {
+	_ = godotenv.Load()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	db, err := database.NewPostgres(cfg.DatabaseURL, false)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.Category{}); err != nil {
+		log.Fatalf("failed to run migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	categories := service.NewCategoryService(db, cfg.CategoryUndoTTL, nil)
+
+	merged, err := categories.MergeDuplicates(ctx)
+	if err != nil {
+		log.Fatalf("merge duplicates: %v", err)
+	}
+
+	if err := categories.EnsureNameIndex(ctx); err != nil {
+		log.Fatalf("categories: %v", err)
+	}
+
+	log.Printf("merged %d duplicate categories", merged)
+}
@@ -11,18 +11,51 @@ import (
 
 // Config groups every runtime configuration needed by the API server.
 type Config struct {
-	Env               string
-	Port              int
-	DatabaseURL       string
-	RedisURL          string
-	SessionCookieName string
-	SessionTTL        time.Duration
-	CorsOrigins       []string
+	Env                   string
+	Port                  int
+	DatabaseURL           string
+	RedisURL              string
+	SessionCookieName     string
+	SessionTTL            time.Duration
+	SessionMaxAge         time.Duration
+	CorsOrigins           []string
+	TrashRetention        time.Duration
+	CategoryUndoTTL       time.Duration
+	DefaultCategoryPack   string
+	DefaultLocale         string
+	CategoryTemplatesFile string
+	AppURL                string
+	MailFrom              string
+	SMTPHost              string
+	SMTPPort              int
+	SMTPUsername          string
+	SMTPPassword          string
+	PasswordResetTTL      time.Duration
+	EmailVerificationTTL  time.Duration
+	RequireVerifiedEmail  bool
+	TOTPIssuer            string
+	OIDCIssuerURL         string
+	OIDCClientID          string
+	OIDCClientSecret      string
+	OIDCRedirectURL       string
+	ProxyHeader           string
+	TrustedProxies        []string
 }
 
 const (
 	defaultPort         = 3000
 	defaultSessionTTL   = 30 * 24 * time.Hour
+	defaultSessionMax   = 90 * 24 * time.Hour
+	defaultTrashTTL     = 30 * 24 * time.Hour
+	defaultUndoTTL      = 10 * time.Minute
+	defaultResetTTL     = time.Hour
+	defaultVerifyTTL    = 24 * time.Hour
+	defaultSMTPPort     = 587
+	defaultAppURL       = "http://localhost:5173"
+	defaultMailFrom     = "no-reply@localhost"
+	defaultTOTPIssuer   = "Expenses"
+	defaultCategoryPack = "basic"
+	defaultLocale       = "EN"
 	defaultCookieName   = "sessionID"
 	defaultEnvironment  = "DEV"
 	corsOriginsFallback = "[\"*\"]"
@@ -31,9 +64,33 @@ const (
 // Load builds a Config based on the environment variables present.
 func Load() (Config, error) {
 	cfg := Config{
-		Env:               strings.ToUpper(getEnv("ENV", defaultEnvironment)),
-		SessionCookieName: getEnv("SESSION_COOKIE_NAME", defaultCookieName),
-		SessionTTL:        defaultSessionTTL,
+		Env:                   strings.ToUpper(getEnv("ENV", defaultEnvironment)),
+		SessionCookieName:     getEnv("SESSION_COOKIE_NAME", defaultCookieName),
+		SessionTTL:            defaultSessionTTL,
+		SessionMaxAge:         defaultSessionMax,
+		TrashRetention:        defaultTrashTTL,
+		CategoryUndoTTL:       defaultUndoTTL,
+		DefaultLocale:         strings.ToUpper(getEnv("DEFAULT_LOCALE", defaultLocale)),
+		CategoryTemplatesFile: os.Getenv("CATEGORY_TEMPLATES_FILE"),
+		AppURL:                strings.TrimRight(getEnv("APP_URL", defaultAppURL), "/"),
+		MailFrom:              getEnv("MAIL_FROM", defaultMailFrom),
+		SMTPHost:              os.Getenv("SMTP_HOST"),
+		SMTPPort:              defaultSMTPPort,
+		SMTPUsername:          os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:          os.Getenv("SMTP_PASSWORD"),
+		PasswordResetTTL:      defaultResetTTL,
+		EmailVerificationTTL:  defaultVerifyTTL,
+		TOTPIssuer:            getEnv("TOTP_ISSUER", defaultTOTPIssuer),
+		OIDCIssuerURL:         os.Getenv("OIDC_ISSUER_URL"),
+		OIDCClientID:          os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:      os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:       os.Getenv("OIDC_REDIRECT_URL"),
+		ProxyHeader:           os.Getenv("PROXY_HEADER"),
+	}
+
+	cfg.DefaultCategoryPack = getEnv("DEFAULT_CATEGORY_PACK", defaultCategoryPack)
+	if strings.EqualFold(cfg.DefaultCategoryPack, "none") {
+		cfg.DefaultCategoryPack = ""
 	}
 
 	if ttlStr := os.Getenv("SESSION_TTL_HOURS"); ttlStr != "" {
@@ -42,6 +99,48 @@ func Load() (Config, error) {
 		}
 	}
 
+	if maxAgeStr := os.Getenv("SESSION_MAX_AGE_HOURS"); maxAgeStr != "" {
+		if maxAge, err := strconv.Atoi(maxAgeStr); err == nil && maxAge > 0 {
+			cfg.SessionMaxAge = time.Duration(maxAge) * time.Hour
+		}
+	}
+
+	if daysStr := os.Getenv("TRASH_RETENTION_DAYS"); daysStr != "" {
+		if days, err := strconv.Atoi(daysStr); err == nil && days > 0 {
+			cfg.TrashRetention = time.Duration(days) * 24 * time.Hour
+		}
+	}
+
+	if minutesStr := os.Getenv("CATEGORY_UNDO_WINDOW_MINUTES"); minutesStr != "" {
+		if minutes, err := strconv.Atoi(minutesStr); err == nil && minutes > 0 {
+			cfg.CategoryUndoTTL = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	if portStr := os.Getenv("SMTP_PORT"); portStr != "" {
+		if port, err := strconv.Atoi(portStr); err == nil && port > 0 {
+			cfg.SMTPPort = port
+		}
+	}
+
+	if minutesStr := os.Getenv("PASSWORD_RESET_TTL_MINUTES"); minutesStr != "" {
+		if minutes, err := strconv.Atoi(minutesStr); err == nil && minutes > 0 {
+			cfg.PasswordResetTTL = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	if hoursStr := os.Getenv("EMAIL_VERIFICATION_TTL_HOURS"); hoursStr != "" {
+		if hours, err := strconv.Atoi(hoursStr); err == nil && hours > 0 {
+			cfg.EmailVerificationTTL = time.Duration(hours) * time.Hour
+		}
+	}
+
+	if requireStr := os.Getenv("REQUIRE_EMAIL_VERIFICATION"); requireStr != "" {
+		if require, err := strconv.ParseBool(requireStr); err == nil {
+			cfg.RequireVerifiedEmail = require
+		}
+	}
+
 	cfg.Port = parsePort(getEnv("PORT", strconv.Itoa(defaultPort)))
 	cfg.DatabaseURL = os.Getenv("DATABASE_URL")
 	cfg.RedisURL = os.Getenv("REDIS_URL")
@@ -54,7 +153,20 @@ func Load() (Config, error) {
 		return Config{}, fmt.Errorf("REDIS_URL is required")
 	}
 
+	if cfg.Env == "PROD" && cfg.SMTPHost == "" {
+		return Config{}, fmt.Errorf("SMTP_HOST is required in PROD")
+	}
+
+	if cfg.OIDCIssuerURL != "" && (cfg.OIDCClientID == "" || cfg.OIDCRedirectURL == "") {
+		return Config{}, fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER_URL")
+	}
+
 	cfg.CorsOrigins = parseOrigins(getEnv("CORS_ORIGINS", corsOriginsFallback))
+	cfg.TrustedProxies = parseList(os.Getenv("TRUSTED_PROXIES"))
+
+	if cfg.ProxyHeader != "" && len(cfg.TrustedProxies) == 0 {
+		return Config{}, fmt.Errorf("TRUSTED_PROXIES is required with PROXY_HEADER")
+	}
 
 	return cfg, nil
 }
@@ -98,3 +210,15 @@ func parseOrigins(raw string) []string {
 
 	return []string{cleaned}
 }
+
+// parseList splits a comma separated list, dropping empty entries.
+func parseList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
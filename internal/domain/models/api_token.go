@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// APIToken is a personal access token. Only the SHA-256 hash of the secret is stored; Prefix helps users tell
+// their tokens apart.
+type APIToken struct {
+	TokenID    string     `gorm:"column:token_id;type:uuid;primaryKey"`
+	UserID     string     `gorm:"column:user_id;index"`
+	Name       string     `gorm:"column:name"`
+	Prefix     string     `gorm:"column:prefix"`
+	TokenHash  string     `gorm:"column:token_hash;uniqueIndex"`
+	Scope      TokenScope `gorm:"column:scope"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at"`
+}
+
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
@@ -8,15 +8,21 @@ import (
 
 // Category groups user transactions.
 type Category struct {
-	CategoryID string          `gorm:"column:category_id;type:uuid;primaryKey"`
-	Type       TransactionType `gorm:"column:type"`
-	Name       string          `gorm:"column:name"`
-	Note       string          `gorm:"column:note"`
-	UserID     string          `gorm:"column:user_id"`
-	CreatedAt  time.Time       `gorm:"column:created_at"`
-	UpdatedAt  time.Time       `gorm:"column:updated_at"`
-	DeletedAt  gorm.DeletedAt  `gorm:"column:deleted_at"`
-	User       *User           `gorm:"foreignKey:UserID"`
+	CategoryID     string          `gorm:"column:category_id;type:uuid;primaryKey"`
+	Type           TransactionType `gorm:"column:type"`
+	Name           string          `gorm:"column:name"`
+	NormalizedName string          `gorm:"column:normalized_name"`
+	Note           string          `gorm:"column:note"`
+	ParentID       *string         `gorm:"column:parent_id;index"`
+	Color          string          `gorm:"column:color"`
+	Icon           string          `gorm:"column:icon"`
+	Position       int             `gorm:"column:position;default:0"`
+	Archived       bool            `gorm:"column:archived;default:false"`
+	UserID         string          `gorm:"column:user_id"`
+	CreatedAt      time.Time       `gorm:"column:created_at"`
+	UpdatedAt      time.Time       `gorm:"column:updated_at"`
+	DeletedAt      gorm.DeletedAt  `gorm:"column:deleted_at"`
+	User           *User           `gorm:"foreignKey:UserID"`
 }
 
 func (Category) TableName() string {
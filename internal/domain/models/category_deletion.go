@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// CategoryDeletion records which transactions and subcategories a category deletion touched so it can be reversed.
+type CategoryDeletion struct {
+	DeletionID     string               `gorm:"column:deletion_id;type:uuid;primaryKey"`
+	CategoryID     string               `gorm:"column:category_id;index"`
+	UserID         string               `gorm:"column:user_id"`
+	Mode           CategoryDeletionMode `gorm:"column:mode"`
+	TransactionIDs StringList           `gorm:"column:transaction_ids;type:text"`
+	ChildIDs       StringList           `gorm:"column:child_ids;type:text"`
+	CreatedAt      time.Time            `gorm:"column:created_at"`
+}
+
+func (CategoryDeletion) TableName() string {
+	return "category_deletions"
+}
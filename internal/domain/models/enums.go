@@ -19,6 +19,22 @@ const (
 	CurrencyEUR Currency = "EUR"
 )
 
+// CategoryDeletionMode tells what happened to the transactions of a deleted category.
+type CategoryDeletionMode string
+
+const (
+	CategoryDeletionDeleteTransactions CategoryDeletionMode = "DELETE_TRANSACTIONS"
+	CategoryDeletionUnlinkTransactions CategoryDeletionMode = "UNLINK_TRANSACTIONS"
+)
+
+// TokenScope tells what an API token may do: READ tokens are limited to safe (GET/HEAD) requests.
+type TokenScope string
+
+const (
+	TokenScopeRead  TokenScope = "READ"
+	TokenScopeWrite TokenScope = "WRITE"
+)
+
 // Month enumerates the supported calendar months.
 type Month string
 
@@ -36,3 +52,30 @@ const (
 	MonthNovember  Month = "NOVEMBER"
 	MonthDecember  Month = "DECEMBER"
 )
+
+// Months lists the calendar months in order.
+var Months = []Month{
+	MonthJanuary,
+	MonthFebruary,
+	MonthMarch,
+	MonthApril,
+	MonthMay,
+	MonthJune,
+	MonthJuly,
+	MonthAugust,
+	MonthSeptember,
+	MonthOctober,
+	MonthNovember,
+	MonthDecember,
+}
+
+// Number returns the 1-based position of the month in the calendar, or 0 for unknown values.
+func (m Month) Number() int {
+	for idx, month := range Months {
+		if month == m {
+			return idx + 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// NetWorthSnapshot stores the net worth of a user at the end of a month, converted to UYU.
+type NetWorthSnapshot struct {
+	SnapshotID  string    `gorm:"column:snapshot_id;type:uuid;primaryKey"`
+	UserID      string    `gorm:"column:user_id;uniqueIndex:idx_net_worth_user_period"`
+	Year        int       `gorm:"column:year;uniqueIndex:idx_net_worth_user_period"`
+	Month       Month     `gorm:"column:month;uniqueIndex:idx_net_worth_user_period"`
+	Cash        float64   `gorm:"column:cash"`
+	Savings     float64   `gorm:"column:savings"`
+	Liabilities float64   `gorm:"column:liabilities"`
+	NetWorth    float64   `gorm:"column:net_worth"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at"`
+}
+
+func (NetWorthSnapshot) TableName() string {
+	return "net_worth_snapshots"
+}
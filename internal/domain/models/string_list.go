@@ -0,0 +1,47 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// StringList stores a list of strings as a JSON text column, portable across PostgreSQL and SQLite.
+type StringList []string
+
+// Value implements driver.Valuer.
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+
+	encoded, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(encoded), nil
+}
+
+// Scan implements sql.Scanner.
+func (l *StringList) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", value)
+	}
+
+	if len(raw) == 0 {
+		*l = nil
+		return nil
+	}
+
+	return json.Unmarshal(raw, (*[]string)(l))
+}
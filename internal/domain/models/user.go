@@ -8,16 +8,20 @@ import (
 
 // User mirrors the users table present in the original project.
 type User struct {
-	UserID       string         `gorm:"column:user_id;type:uuid;primaryKey"`
-	Email        string         `gorm:"column:email;uniqueIndex"`
-	FirstName    string         `gorm:"column:first_name"`
-	LastName     string         `gorm:"column:last_name"`
-	Password     string         `gorm:"column:password"`
-	CreatedAt    time.Time      `gorm:"column:created_at"`
-	UpdatedAt    time.Time      `gorm:"column:updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"column:deleted_at"`
-	Categories   []Category     `gorm:"foreignKey:UserID"`
-	Transactions []Transaction  `gorm:"foreignKey:UserID"`
+	UserID          string         `gorm:"column:user_id;type:uuid;primaryKey"`
+	Email           string         `gorm:"column:email;uniqueIndex"`
+	FirstName       string         `gorm:"column:first_name"`
+	LastName        string         `gorm:"column:last_name"`
+	Password        string         `gorm:"column:password"`
+	EmailVerifiedAt *time.Time     `gorm:"column:email_verified_at"`
+	TOTPSecret      string         `gorm:"column:totp_secret"`
+	TOTPEnabledAt   *time.Time     `gorm:"column:totp_enabled_at"`
+	RecoveryCodes   StringList     `gorm:"column:totp_recovery_codes;type:text"`
+	CreatedAt       time.Time      `gorm:"column:created_at"`
+	UpdatedAt       time.Time      `gorm:"column:updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"column:deleted_at"`
+	Categories      []Category     `gorm:"foreignKey:UserID"`
+	Transactions    []Transaction  `gorm:"foreignKey:UserID"`
 }
 
 // TableName tells GORM the exact table name, avoiding pluralization issues.
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID Connect provider, identified by issuer and subject.
+type UserIdentity struct {
+	IdentityID  string     `gorm:"column:identity_id;type:uuid;primaryKey"`
+	UserID      string     `gorm:"column:user_id;index"`
+	Issuer      string     `gorm:"column:issuer;uniqueIndex:idx_user_identities_issuer_subject"`
+	Subject     string     `gorm:"column:subject;uniqueIndex:idx_user_identities_issuer_subject"`
+	Email       string     `gorm:"column:email"`
+	LastLoginAt *time.Time `gorm:"column:last_login_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
@@ -12,19 +14,35 @@ import (
 	"github.com/iperez/new-expenses-go/pkg/response"
 )
 
-// AuthHandler exposes login/logout endpoints.
+// oidcStateCookie binds an OpenID Connect login to the browser that started it.
+const oidcStateCookie = "oidcState"
+
+// AuthHandler exposes login/logout endpoints. The OpenID Connect routes exist only when oidc is configured.
 type AuthHandler struct {
-	auth *service.AuthService
-	cfg  config.Config
+	auth      *service.AuthService
+	passwords *service.PasswordService
+	oidc      *service.OIDCService
+	cfg       config.Config
 }
 
-func NewAuthHandler(auth *service.AuthService, cfg config.Config) *AuthHandler {
-	return &AuthHandler{auth: auth, cfg: cfg}
+func NewAuthHandler(auth *service.AuthService, passwords *service.PasswordService, oidc *service.OIDCService, cfg config.Config) *AuthHandler {
+	return &AuthHandler{auth: auth, passwords: passwords, oidc: oidc, cfg: cfg}
 }
 
 func (h *AuthHandler) Register(router fiber.Router) {
 	router.Post("/login", h.Login)
-	router.Delete("/logout", middleware.RequireAuth(), h.Logout)
+	router.Post("/2fa/verify", h.CompleteLogin)
+	router.Delete("/logout", middleware.RequireAuth(), middleware.RequireSession(), h.Logout)
+	router.Post("/password/forgot", h.ForgotPassword)
+	router.Post("/password/reset", h.ResetPassword)
+	router.Get("/sessions", middleware.RequireAuth(), middleware.RequireSession(), h.Sessions)
+	router.Delete("/sessions", middleware.RequireAuth(), middleware.RequireSession(), h.LogoutEverywhere)
+	router.Delete("/sessions/:id", middleware.RequireAuth(), middleware.RequireSession(), h.RevokeSession)
+
+	if h.oidc != nil {
+		router.Get("/oidc/login", h.OIDCLogin)
+		router.Get("/oidc/callback", h.OIDCCallback)
+	}
 }
 
 type loginRequest struct {
@@ -45,16 +63,96 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return apperror.New(apperror.ServerParamsMissing, "Email and password are required")
 	}
 
-	user, sessionID, err := h.auth.Login(c.UserContext(), payload.Email, payload.Password, middleware.SessionID(c))
+	user, sessionID, err := h.auth.Login(c.UserContext(), payload.Email, payload.Password, middleware.SessionID(c), sessionInfo(c))
 	if err != nil {
 		return err
 	}
 
 	h.setSessionCookie(c, sessionID)
 
+	if user.TOTPEnabledAt != nil {
+		return c.Status(fiber.StatusAccepted).JSON(response.Success(pendingLoginResponse{TwoFactorRequired: true}))
+	}
+
 	return c.JSON(response.Success(newUserResponse(user)))
 }
 
+type pendingLoginResponse struct {
+	TwoFactorRequired bool `json:"twoFactorRequired"`
+}
+
+// CompleteLogin finishes a login waiting for its second factor, sent as a TOTP or recovery code.
+func (h *AuthHandler) CompleteLogin(c *fiber.Ctx) error {
+	code, err := parseTwoFactorCode(c)
+	if err != nil {
+		return err
+	}
+
+	user, sessionID, err := h.auth.CompleteLogin(c.UserContext(), middleware.SessionID(c), code)
+	if err != nil {
+		return err
+	}
+
+	h.setSessionCookie(c, sessionID)
+
+	return c.JSON(response.Success(newUserResponse(user)))
+}
+
+// OIDCLogin redirects the browser to the identity provider.
+func (h *AuthHandler) OIDCLogin(c *fiber.Ctx) error {
+	authURL, state, err := h.oidc.Start(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		HTTPOnly: true,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		Secure:   h.cfg.Env == "PROD",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// OIDCCallback completes the login when the provider sends the browser back, then redirects it to the frontend.
+func (h *AuthHandler) OIDCCallback(c *fiber.Ctx) error {
+	state := c.Query("state")
+	expected := c.Cookies(oidcStateCookie)
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     "/api/auth/oidc",
+		MaxAge:   -1,
+		HTTPOnly: true,
+	})
+
+	if c.Query("error") != "" {
+		return apperror.New(apperror.AuthOIDCInvalid, c.Query("error"))
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(expected)) != 1 {
+		return apperror.New(apperror.AuthOIDCInvalid, nil)
+	}
+
+	user, sessionID, err := h.oidc.Callback(c.UserContext(), state, c.Query("code"), middleware.SessionID(c), sessionInfo(c))
+	if err != nil {
+		return err
+	}
+
+	h.setSessionCookie(c, sessionID)
+
+	if user.TOTPEnabledAt != nil {
+		return c.Redirect(h.cfg.AppURL+"/login?twoFactorRequired=true", fiber.StatusFound)
+	}
+
+	return c.Redirect(h.cfg.AppURL+"/", fiber.StatusFound)
+}
+
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	if err := h.auth.Logout(c.UserContext(), middleware.SessionID(c)); err != nil {
 		return err
@@ -65,21 +163,98 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	return c.JSON(response.Success(nil))
 }
 
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ForgotPassword always succeeds so it cannot be used to find out which emails are registered.
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var payload forgotPasswordRequest
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	payload.Email = strings.TrimSpace(payload.Email)
+	if payload.Email == "" {
+		return apperror.New(apperror.ServerParamsMissing, "Email is required")
+	}
+
+	if err := h.passwords.RequestReset(c.UserContext(), payload.Email); err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var payload service.ResetPasswordInput
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	if err := h.passwords.Reset(c.UserContext(), payload); err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+func (h *AuthHandler) Sessions(c *fiber.Ctx) error {
+	sessions, err := h.auth.Sessions(c.UserContext(), middleware.UserID(c), middleware.SessionID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(sessions))
+}
+
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	publicID := c.Params("id")
+	if err := h.auth.RevokeSession(c.UserContext(), middleware.UserID(c), publicID); err != nil {
+		return err
+	}
+
+	if publicID == service.PublicSessionID(middleware.SessionID(c)) {
+		h.clearSessionCookie(c)
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+// LogoutEverywhere ends every session of the user, including the current one.
+func (h *AuthHandler) LogoutEverywhere(c *fiber.Ctx) error {
+	if err := h.auth.LogoutEverywhere(c.UserContext(), middleware.UserID(c), ""); err != nil {
+		return err
+	}
+
+	h.clearSessionCookie(c)
+
+	return c.JSON(response.Success(nil))
+}
+
+func sessionInfo(c *fiber.Ctx) service.SessionInfo {
+	return service.SessionInfo{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+}
+
 func (h *AuthHandler) setSessionCookie(c *fiber.Ctx, sessionID string) {
 	c.Cookie(&fiber.Cookie{
 		Name:     h.cfg.SessionCookieName,
 		Value:    sessionID,
 		HTTPOnly: true,
 		Path:     "/",
-		MaxAge:   int(h.cfg.SessionTTL.Seconds()),
+		MaxAge:   int(max(h.cfg.SessionTTL, h.cfg.SessionMaxAge).Seconds()),
 		Secure:   h.cfg.Env == "PROD",
 		SameSite: fiber.CookieSameSiteLaxMode,
 	})
 }
 
 func (h *AuthHandler) clearSessionCookie(c *fiber.Ctx) {
+	clearSessionCookie(c, h.cfg)
+}
+
+func clearSessionCookie(c *fiber.Ctx, cfg config.Config) {
 	c.Cookie(&fiber.Cookie{
-		Name:     h.cfg.SessionCookieName,
+		Name:     cfg.SessionCookieName,
 		Value:    "",
 		Path:     "/",
 		MaxAge:   -1,
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/iperez/new-expenses-go/internal/domain/models"
 	"github.com/iperez/new-expenses-go/internal/http/middleware"
 	"github.com/iperez/new-expenses-go/internal/service"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
 	"github.com/iperez/new-expenses-go/pkg/response"
 )
 
@@ -21,12 +24,23 @@ func NewCategoryHandler(categories *service.CategoryService) *CategoryHandler {
 func (h *CategoryHandler) Register(router fiber.Router) {
 	router.Get("/", middleware.RequireAuth(), h.List)
 	router.Post("/", middleware.RequireAuth(), h.Create)
+	router.Put("/order", middleware.RequireAuth(), h.Reorder)
+	router.Get("/templates", middleware.RequireAuth(), h.Templates)
+	router.Post("/templates/:pack", middleware.RequireAuth(), h.ApplyTemplate)
 	router.Get("/:categoryId", middleware.RequireAuth(), h.Get)
+	router.Get("/:categoryId/stats", middleware.RequireAuth(), h.Stats)
+	router.Patch("/:categoryId", middleware.RequireAuth(), h.Update)
 	router.Delete("/:categoryId", middleware.RequireAuth(), h.Delete)
+	router.Post("/:categoryId/undo-delete", middleware.RequireAuth(), h.UndoDelete)
+	router.Post("/:categoryId/merge", middleware.RequireAuth(), h.Merge)
 }
 
 func (h *CategoryHandler) List(c *fiber.Ctx) error {
-	categories, err := h.categories.List(c.UserContext(), middleware.UserID(c))
+	if c.QueryBool("tree") {
+		return h.tree(c)
+	}
+
+	categories, err := h.categories.List(c.UserContext(), middleware.UserID(c), c.QueryBool("archived"))
 	if err != nil {
 		return err
 	}
@@ -39,6 +53,15 @@ func (h *CategoryHandler) List(c *fiber.Ctx) error {
 	return c.JSON(response.Success(responses))
 }
 
+func (h *CategoryHandler) tree(c *fiber.Ctx) error {
+	roots, err := h.categories.Tree(c.UserContext(), middleware.UserID(c), c.QueryBool("archived"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(newCategoryTreeResponses(roots)))
+}
+
 func (h *CategoryHandler) Create(c *fiber.Ctx) error {
 	var payload service.CreateCategoryInput
 	if err := c.BodyParser(&payload); err != nil {
@@ -62,21 +85,131 @@ func (h *CategoryHandler) Get(c *fiber.Ctx) error {
 	return c.JSON(response.Success(newCategoryResponse(category)))
 }
 
+func (h *CategoryHandler) Reorder(c *fiber.Ctx) error {
+	var payload service.ReorderCategoriesInput
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	if err := h.categories.Reorder(c.UserContext(), middleware.UserID(c), payload); err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+func (h *CategoryHandler) Templates(c *fiber.Ctx) error {
+	locale := strings.ToUpper(c.Query("locale", service.DefaultLocale))
+
+	packs := make([]templatePackResponse, 0)
+	for _, pack := range h.categories.TemplatePacks() {
+		entries, err := h.categories.Template(pack)
+		if err != nil {
+			return err
+		}
+
+		packResponse := templatePackResponse{Pack: pack, Categories: make([]templateCategoryResponse, 0, len(entries))}
+		for _, entry := range entries {
+			packResponse.Categories = append(packResponse.Categories, templateCategoryResponse{Type: string(entry.Type), Name: entry.Name(locale)})
+		}
+		packs = append(packs, packResponse)
+	}
+
+	return c.JSON(response.Success(packs))
+}
+
+func (h *CategoryHandler) ApplyTemplate(c *fiber.Ctx) error {
+	locale := strings.ToUpper(c.Query("locale", service.DefaultLocale))
+
+	categories, err := h.categories.ApplyTemplate(c.UserContext(), nil, middleware.UserID(c), c.Params("pack"), locale)
+	if err != nil {
+		return err
+	}
+
+	responses := make([]categoryResponse, 0, len(categories))
+	for idx := range categories {
+		responses = append(responses, newCategoryResponse(&categories[idx]))
+	}
+
+	return c.JSON(response.Success(responses))
+}
+
+func (h *CategoryHandler) Stats(c *fiber.Ctx) error {
+	months := c.QueryInt("months", 12)
+	if months < 1 || months > 120 {
+		return apperror.New(apperror.ServerParamsMissing, "Months must be between 1 and 120")
+	}
+
+	stats, err := h.categories.Stats(c.UserContext(), middleware.UserID(c), c.Params("categoryId"), months, c.QueryBool("includeSubcategories"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(stats))
+}
+
+func (h *CategoryHandler) Update(c *fiber.Ctx) error {
+	var payload service.UpdateCategoryInput
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	category, err := h.categories.Update(c.UserContext(), middleware.UserID(c), c.Params("categoryId"), payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(newCategoryResponse(category)))
+}
+
 func (h *CategoryHandler) Delete(c *fiber.Ctx) error {
 	deleteTransactions := c.QueryBool("deleteTransactions")
 
-	if err := h.categories.Delete(c.UserContext(), middleware.UserID(c), c.Params("categoryId"), deleteTransactions); err != nil {
+	deletion, err := h.categories.Delete(c.UserContext(), middleware.UserID(c), c.Params("categoryId"), deleteTransactions)
+	if err != nil {
 		return err
 	}
 
-	return c.JSON(response.Success(nil))
+	return c.JSON(response.Success(fiber.Map{
+		"mode":           deletion.Mode,
+		"transactionIds": append([]string{}, deletion.TransactionIDs...),
+		"undoUntil":      deletion.CreatedAt.Add(h.categories.UndoWindow()),
+	}))
+}
+
+func (h *CategoryHandler) UndoDelete(c *fiber.Ctx) error {
+	category, err := h.categories.UndoDelete(c.UserContext(), middleware.UserID(c), c.Params("categoryId"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(newCategoryResponse(category)))
+}
+
+func (h *CategoryHandler) Merge(c *fiber.Ctx) error {
+	var payload service.MergeCategoriesInput
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	category, err := h.categories.Merge(c.UserContext(), middleware.UserID(c), c.Params("categoryId"), payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(newCategoryResponse(category)))
 }
 
 type categoryResponse struct {
-	CategoryID string `json:"categoryId"`
-	Type       string `json:"type"`
-	Name       string `json:"name"`
-	Note       string `json:"note"`
+	CategoryID string  `json:"categoryId"`
+	Type       string  `json:"type"`
+	Name       string  `json:"name"`
+	Note       string  `json:"note"`
+	ParentID   *string `json:"parentId"`
+	Color      string  `json:"color"`
+	Icon       string  `json:"icon"`
+	Position   int     `json:"position"`
+	Archived   bool    `json:"archived"`
 }
 
 func newCategoryResponse(category *models.Category) categoryResponse {
@@ -85,5 +218,37 @@ func newCategoryResponse(category *models.Category) categoryResponse {
 		Type:       string(category.Type),
 		Name:       category.Name,
 		Note:       category.Note,
+		ParentID:   category.ParentID,
+		Color:      category.Color,
+		Icon:       category.Icon,
+		Position:   category.Position,
+		Archived:   category.Archived,
 	}
 }
+
+type templatePackResponse struct {
+	Pack       string                     `json:"pack"`
+	Categories []templateCategoryResponse `json:"categories"`
+}
+
+type templateCategoryResponse struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type categoryTreeResponse struct {
+	categoryResponse
+	Children []categoryTreeResponse `json:"children"`
+}
+
+func newCategoryTreeResponses(nodes []*service.CategoryNode) []categoryTreeResponse {
+	responses := make([]categoryTreeResponse, 0, len(nodes))
+	for _, node := range nodes {
+		responses = append(responses, categoryTreeResponse{
+			categoryResponse: newCategoryResponse(&node.Category),
+			Children:         newCategoryTreeResponses(node.Children),
+		})
+	}
+
+	return responses
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/iperez/new-expenses-go/internal/http/middleware"
+	"github.com/iperez/new-expenses-go/internal/service"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+	"github.com/iperez/new-expenses-go/pkg/response"
+)
+
+// ReportHandler exposes the aggregated reports used by the charts.
+type ReportHandler struct {
+	reports *service.ReportService
+}
+
+func NewReportHandler(reports *service.ReportService) *ReportHandler {
+	return &ReportHandler{reports: reports}
+}
+
+func (h *ReportHandler) Register(router fiber.Router) {
+	router.Get("/monthly", middleware.RequireAuth(), h.Monthly)
+	router.Get("/categories", middleware.RequireAuth(), h.Categories)
+	router.Get("/compare", middleware.RequireAuth(), h.Compare)
+	router.Get("/forecast", middleware.RequireAuth(), h.Forecast)
+	router.Get("/net-worth", middleware.RequireAuth(), h.NetWorth)
+	router.Get("/net-worth/history", middleware.RequireAuth(), h.NetWorthHistory)
+}
+
+func (h *ReportHandler) Monthly(c *fiber.Ctx) error {
+	filters, err := parseFilters(c)
+	if err != nil {
+		return err
+	}
+
+	to := service.PeriodOf(time.Now())
+	if value := c.Query("to"); value != "" {
+		if to, err = service.ParsePeriod(value); err != nil {
+			return apperror.New(apperror.ServerParamsMissing, "to must use the YYYY-MM format")
+		}
+	}
+
+	from := to.AddMonths(-11)
+	if value := c.Query("from"); value != "" {
+		if from, err = service.ParsePeriod(value); err != nil {
+			return apperror.New(apperror.ServerParamsMissing, "from must use the YYYY-MM format")
+		}
+	}
+
+	entries, err := h.reports.Monthly(c.UserContext(), middleware.UserID(c), from, to, filters)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(entries))
+}
+
+func (h *ReportHandler) Categories(c *fiber.Ctx) error {
+	filters, err := parseFilters(c)
+	if err != nil {
+		return err
+	}
+
+	options := service.BreakdownOptions{Top: c.QueryInt("top"), Rollup: c.QueryBool("rollup")}
+	if options.Top < 0 {
+		return apperror.New(apperror.ServerParamsMissing, "top must be a positive number")
+	}
+
+	breakdown, err := h.reports.CategoryBreakdown(c.UserContext(), middleware.UserID(c), filters, options)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(breakdown))
+}
+
+// Compare reports the changes between the period selected by day/month/year (the current month by default) and the
+// one selected by compareDay/compareMonth/compareYear, whose missing parts are taken from the former. Without compare
+// parameters the previous month, or year, is used.
+func (h *ReportHandler) Compare(c *fiber.Ctx) error {
+	current, err := parseFilters(c)
+	if err != nil {
+		return err
+	}
+
+	if current.Day == nil && current.Month == nil && current.Year == nil {
+		now := service.PeriodOf(time.Now())
+		current.Month, current.Year = &now.Month, &now.Year
+	}
+
+	day, month, year, err := parseDateParams(c, "compareDay", "compareMonth", "compareYear")
+	if err != nil {
+		return err
+	}
+
+	previous, ok := service.PreviousPeriod(current)
+	if day != nil || month != nil || year != nil {
+		previous, ok = current, true
+		if day != nil {
+			previous.Day = day
+		}
+		if month != nil {
+			previous.Month = month
+		}
+		if year != nil {
+			previous.Year = year
+		}
+	}
+
+	if !ok {
+		return apperror.New(apperror.ServerParamsMissing, "compareDay, compareMonth or compareYear is required for this period")
+	}
+
+	comparison, err := h.reports.Compare(c.UserContext(), middleware.UserID(c), current, previous, c.QueryBool("rollup"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(comparison))
+}
+
+func (h *ReportHandler) Forecast(c *fiber.Ctx) error {
+	filters, err := parseFilters(c)
+	if err != nil {
+		return err
+	}
+
+	today := time.Now()
+	if value := c.Query("date"); value != "" {
+		if today, err = time.Parse(time.DateOnly, value); err != nil {
+			return apperror.New(apperror.ServerParamsMissing, "date must use the YYYY-MM-DD format")
+		}
+	}
+
+	forecast, err := h.reports.Forecast(c.UserContext(), middleware.UserID(c), today, c.QueryInt("months", 3), filters)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(forecast))
+}
+
+func (h *ReportHandler) NetWorth(c *fiber.Ctx) error {
+	at := service.PeriodOf(time.Now())
+	if value := c.Query("at"); value != "" {
+		var err error
+		if at, err = service.ParsePeriod(value); err != nil {
+			return apperror.New(apperror.ServerParamsMissing, "at must use the YYYY-MM format")
+		}
+	}
+
+	worth, err := h.reports.NetWorth(c.UserContext(), middleware.UserID(c), at)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(worth))
+}
+
+func (h *ReportHandler) NetWorthHistory(c *fiber.Ctx) error {
+	var err error
+
+	to := service.PeriodOf(time.Now())
+	if value := c.Query("to"); value != "" {
+		if to, err = service.ParsePeriod(value); err != nil {
+			return apperror.New(apperror.ServerParamsMissing, "to must use the YYYY-MM format")
+		}
+	}
+
+	from := to.AddMonths(-11)
+	if value := c.Query("from"); value != "" {
+		if from, err = service.ParsePeriod(value); err != nil {
+			return apperror.New(apperror.ServerParamsMissing, "from must use the YYYY-MM format")
+		}
+	}
+
+	history, err := h.reports.NetWorthHistory(c.UserContext(), middleware.UserID(c), from, to)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(history))
+}
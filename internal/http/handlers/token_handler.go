@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/internal/http/middleware"
+	"github.com/iperez/new-expenses-go/internal/service"
+	"github.com/iperez/new-expenses-go/pkg/response"
+)
+
+// TokenHandler manages personal access tokens. Tokens cannot manage tokens, so every route needs a cookie session.
+type TokenHandler struct {
+	tokens *service.APITokenService
+}
+
+func NewTokenHandler(tokens *service.APITokenService) *TokenHandler {
+	return &TokenHandler{tokens: tokens}
+}
+
+func (h *TokenHandler) Register(router fiber.Router) {
+	router.Get("/", middleware.RequireAuth(), middleware.RequireSession(), h.List)
+	router.Post("/", middleware.RequireAuth(), middleware.RequireSession(), h.Create)
+	router.Delete("/:tokenId", middleware.RequireAuth(), middleware.RequireSession(), h.Revoke)
+}
+
+func (h *TokenHandler) List(c *fiber.Ctx) error {
+	tokens, err := h.tokens.List(c.UserContext(), middleware.UserID(c))
+	if err != nil {
+		return err
+	}
+
+	result := make([]tokenResponse, 0, len(tokens))
+	for idx := range tokens {
+		result = append(result, newTokenResponse(&tokens[idx]))
+	}
+
+	return c.JSON(response.Success(result))
+}
+
+func (h *TokenHandler) Create(c *fiber.Ctx) error {
+	var payload service.CreateAPITokenInput
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	token, secret, err := h.tokens.Create(c.UserContext(), middleware.UserID(c), payload)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.Success(createdTokenResponse{
+		tokenResponse: newTokenResponse(token),
+		Token:         secret,
+	}))
+}
+
+func (h *TokenHandler) Revoke(c *fiber.Ctx) error {
+	if err := h.tokens.Revoke(c.UserContext(), middleware.UserID(c), c.Params("tokenId")); err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+type tokenResponse struct {
+	TokenID    string            `json:"tokenId"`
+	Name       string            `json:"name"`
+	Prefix     string            `json:"prefix"`
+	Scope      models.TokenScope `json:"scope"`
+	ExpiresAt  *time.Time        `json:"expiresAt"`
+	LastUsedAt *time.Time        `json:"lastUsedAt"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+// createdTokenResponse is the only response carrying the token secret.
+type createdTokenResponse struct {
+	tokenResponse
+	Token string `json:"token"`
+}
+
+func newTokenResponse(token *models.APIToken) tokenResponse {
+	return tokenResponse{
+		TokenID:    token.TokenID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scope:      token.Scope,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
@@ -81,7 +81,12 @@ func (h *TransactionHandler) List(c *fiber.Ctx) error {
 		return err
 	}
 
-	transactions, err := h.transactions.List(c.UserContext(), middleware.UserID(c), filters)
+	order, err := parseSort(c)
+	if err != nil {
+		return err
+	}
+
+	transactions, err := h.transactions.List(c.UserContext(), middleware.UserID(c), filters, order)
 	if err != nil {
 		return err
 	}
@@ -155,41 +160,129 @@ func (h *TransactionHandler) TotalSavings(c *fiber.Ctx) error {
 func parseFilters(c *fiber.Ctx) (service.TransactionFilters, error) {
 	filters := service.TransactionFilters{}
 	validTypes := service.ValidTransactionTypes()
-	validMonths := service.ValidMonths()
+	validCurrencies := service.ValidCurrencies()
 
-	if typeParam := c.Query("type"); typeParam != "" {
+	for _, typeParam := range queryList(c, "type") {
 		normalized := models.TransactionType(strings.ToUpper(typeParam))
 		if _, ok := validTypes[normalized]; !ok {
 			return filters, apperror.New(apperror.ServerParamsMissing, "Invalid transaction type")
 		}
-		filters.Type = &normalized
+		filters.Types = append(filters.Types, normalized)
+	}
+
+	for _, currencyParam := range queryList(c, "currency") {
+		normalized := models.Currency(strings.ToUpper(currencyParam))
+		if _, ok := validCurrencies[normalized]; !ok {
+			return filters, apperror.New(apperror.ServerParamsMissing, "Invalid currency")
+		}
+		filters.Currencies = append(filters.Currencies, normalized)
+	}
+
+	filters.CategoryIDs = queryList(c, "categoryId")
+	filters.IncludeSubcategories = c.QueryBool("includeSubcategories")
+	filters.Uncategorized = c.QueryBool("uncategorized")
+
+	var err error
+	if filters.Day, filters.Month, filters.Year, err = parseDateParams(c, "day", "month", "year"); err != nil {
+		return filters, err
 	}
 
-	if monthParam := c.Query("month"); monthParam != "" {
+	if minParam := c.Query("minAmount"); minParam != "" {
+		minValue, err := strconv.ParseFloat(minParam, 64)
+		if err != nil || minValue < 0 {
+			return filters, apperror.New(apperror.ServerParamsMissing, "minAmount must be a non-negative number")
+		}
+		filters.MinAmount = &minValue
+	}
+
+	if maxParam := c.Query("maxAmount"); maxParam != "" {
+		maxValue, err := strconv.ParseFloat(maxParam, 64)
+		if err != nil || maxValue < 0 {
+			return filters, apperror.New(apperror.ServerParamsMissing, "maxAmount must be a non-negative number")
+		}
+		filters.MaxAmount = &maxValue
+	}
+
+	if filters.MinAmount != nil && filters.MaxAmount != nil && *filters.MinAmount > *filters.MaxAmount {
+		return filters, apperror.New(apperror.ServerParamsMissing, "minAmount must be lower than or equal to maxAmount")
+	}
+
+	return filters, nil
+}
+
+// parseDateParams reads the day, month and year query parameters stored under the provided keys.
+func parseDateParams(c *fiber.Ctx, dayKey, monthKey, yearKey string) (*int, *models.Month, *int, error) {
+	var (
+		day   *int
+		month *models.Month
+		year  *int
+	)
+
+	if monthParam := c.Query(monthKey); monthParam != "" {
 		normalized := models.Month(strings.ToUpper(monthParam))
-		if _, ok := validMonths[normalized]; !ok {
-			return filters, apperror.New(apperror.ServerParamsMissing, "Invalid month")
+		if _, ok := service.ValidMonths()[normalized]; !ok {
+			return nil, nil, nil, apperror.New(apperror.ServerParamsMissing, "Invalid month")
 		}
-		filters.Month = &normalized
+		month = &normalized
 	}
 
-	if dayParam := c.Query("day"); dayParam != "" {
+	if dayParam := c.Query(dayKey); dayParam != "" {
 		dayValue, err := strconv.Atoi(dayParam)
 		if err != nil || dayValue <= 0 || dayValue > 31 {
-			return filters, apperror.New(apperror.ServerParamsMissing, "Day must be between 1 and 31")
+			return nil, nil, nil, apperror.New(apperror.ServerParamsMissing, "Day must be between 1 and 31")
 		}
-		filters.Day = &dayValue
+		day = &dayValue
 	}
 
-	if yearParam := c.Query("year"); yearParam != "" {
+	if yearParam := c.Query(yearKey); yearParam != "" {
 		yearValue, err := strconv.Atoi(yearParam)
 		if err != nil || yearValue < 2000 {
-			return filters, apperror.New(apperror.ServerParamsMissing, "Year must be >= 2000")
+			return nil, nil, nil, apperror.New(apperror.ServerParamsMissing, "Year must be >= 2000")
 		}
-		filters.Year = &yearValue
+		year = &yearValue
 	}
 
-	return filters, nil
+	return day, month, year, nil
+}
+
+// parseSort reads the sort query parameter using the "field" or "field:direction" format (e.g. sort=amount:asc).
+func parseSort(c *fiber.Ctx) (service.TransactionSort, error) {
+	order := service.TransactionSort{}
+
+	raw := strings.TrimSpace(c.Query("sort"))
+	if raw == "" {
+		return order, nil
+	}
+
+	field, direction, _ := strings.Cut(raw, ":")
+	order.Field = service.TransactionSortField(strings.ToLower(strings.TrimSpace(field)))
+	if _, ok := service.ValidSortFields()[order.Field]; !ok {
+		return order, apperror.New(apperror.ServerParamsMissing, "Sort must be one of date, amount, category or created")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(direction)) {
+	case "", "desc":
+	case "asc":
+		order.Ascending = true
+	default:
+		return order, apperror.New(apperror.ServerParamsMissing, "Sort direction must be asc or desc")
+	}
+
+	return order, nil
+}
+
+// queryList collects every value of a repeatable query parameter, also accepting comma separated values.
+func queryList(c *fiber.Ctx, key string) []string {
+	var values []string
+	for _, raw := range c.Context().QueryArgs().PeekMulti(key) {
+		for _, part := range strings.Split(string(raw), ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+
+	return values
 }
 
 func sanitizeTransactionInput(input *service.CreateTransactionInput) {
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/iperez/new-expenses-go/internal/http/middleware"
+	"github.com/iperez/new-expenses-go/internal/service"
+	"github.com/iperez/new-expenses-go/pkg/response"
+)
+
+// TrashHandler exposes the soft-deleted items so they can be restored or purged.
+type TrashHandler struct {
+	trash *service.TrashService
+}
+
+func NewTrashHandler(trash *service.TrashService) *TrashHandler {
+	return &TrashHandler{trash: trash}
+}
+
+func (h *TrashHandler) Register(router fiber.Router) {
+	router.Get("/", middleware.RequireAuth(), h.List)
+	router.Post("/:id/restore", middleware.RequireAuth(), h.Restore)
+	router.Delete("/:id", middleware.RequireAuth(), h.Purge)
+}
+
+func (h *TrashHandler) List(c *fiber.Ctx) error {
+	contents, err := h.trash.List(c.UserContext(), middleware.UserID(c))
+	if err != nil {
+		return err
+	}
+
+	result := trashResponse{
+		Categories:   make([]trashedCategoryResponse, 0, len(contents.Categories)),
+		Transactions: make([]trashedTransactionResponse, 0, len(contents.Transactions)),
+	}
+
+	for idx := range contents.Categories {
+		category := &contents.Categories[idx]
+		result.Categories = append(result.Categories, trashedCategoryResponse{
+			categoryResponse: newCategoryResponse(category),
+			DeletedAt:        category.DeletedAt.Time,
+		})
+	}
+
+	for idx := range contents.Transactions {
+		transaction := &contents.Transactions[idx]
+		result.Transactions = append(result.Transactions, trashedTransactionResponse{
+			transactionResponse: newTransactionResponse(transaction),
+			DeletedAt:           transaction.DeletedAt.Time,
+		})
+	}
+
+	return c.JSON(response.Success(result))
+}
+
+func (h *TrashHandler) Restore(c *fiber.Ctx) error {
+	itemType, err := h.trash.Restore(c.UserContext(), middleware.UserID(c), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(fiber.Map{"id": c.Params("id"), "type": itemType}))
+}
+
+func (h *TrashHandler) Purge(c *fiber.Ctx) error {
+	if err := h.trash.Purge(c.UserContext(), middleware.UserID(c), c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+type trashResponse struct {
+	Categories   []trashedCategoryResponse    `json:"categories"`
+	Transactions []trashedTransactionResponse `json:"transactions"`
+}
+
+type trashedCategoryResponse struct {
+	categoryResponse
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+type trashedTransactionResponse struct {
+	transactionResponse
+	DeletedAt time.Time `json:"deletedAt"`
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/iperez/new-expenses-go/internal/http/middleware"
+	"github.com/iperez/new-expenses-go/internal/service"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+	"github.com/iperez/new-expenses-go/pkg/response"
+)
+
+// TwoFactorHandler enables and disables TOTP two-factor authentication. The login step lives in AuthHandler.
+type TwoFactorHandler struct {
+	users     *service.UserService
+	auth      *service.AuthService
+	twoFactor *service.TwoFactorService
+}
+
+func NewTwoFactorHandler(users *service.UserService, auth *service.AuthService, twoFactor *service.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{users: users, auth: auth, twoFactor: twoFactor}
+}
+
+func (h *TwoFactorHandler) Register(router fiber.Router) {
+	router.Post("/enroll", middleware.RequireAuth(), middleware.RequireSession(), h.Enroll)
+	router.Post("/confirm", middleware.RequireAuth(), middleware.RequireSession(), h.Confirm)
+	router.Delete("/", middleware.RequireAuth(), middleware.RequireSession(), h.Disable)
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type disableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+func (h *TwoFactorHandler) Enroll(c *fiber.Ctx) error {
+	user, err := h.users.GetByID(c.UserContext(), middleware.UserID(c))
+	if err != nil {
+		return err
+	}
+
+	enrollment, err := h.twoFactor.Enroll(c.UserContext(), user)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(enrollment))
+}
+
+// Confirm enables two-factor authentication and returns the recovery codes, which are not shown again.
+func (h *TwoFactorHandler) Confirm(c *fiber.Ctx) error {
+	code, err := parseTwoFactorCode(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.users.GetByID(c.UserContext(), middleware.UserID(c))
+	if err != nil {
+		return err
+	}
+
+	codes, err := h.twoFactor.Confirm(c.UserContext(), user, code)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(recoveryCodesResponse{RecoveryCodes: codes}))
+}
+
+// Disable turns two-factor authentication off. It asks for the password and a code, like a login.
+func (h *TwoFactorHandler) Disable(c *fiber.Ctx) error {
+	var payload disableTwoFactorRequest
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	if payload.Password == "" || strings.TrimSpace(payload.Code) == "" {
+		return apperror.New(apperror.ServerParamsMissing, "Password and code are required")
+	}
+
+	if err := h.auth.DisableTwoFactor(c.UserContext(), middleware.UserID(c), payload.Password, payload.Code, c.IP()); err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+func parseTwoFactorCode(c *fiber.Ctx) (string, error) {
+	var payload twoFactorCodeRequest
+	if err := c.BodyParser(&payload); err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(payload.Code) == "" {
+		return "", apperror.New(apperror.ServerParamsMissing, "Code is required")
+	}
+
+	return payload.Code, nil
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"log"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/iperez/new-expenses-go/internal/config"
 	"github.com/iperez/new-expenses-go/internal/domain/models"
 	"github.com/iperez/new-expenses-go/internal/http/middleware"
 	"github.com/iperez/new-expenses-go/internal/service"
@@ -12,16 +16,26 @@ import (
 
 // UserHandler wires HTTP requests with the user service.
 type UserHandler struct {
-	users *service.UserService
+	users         *service.UserService
+	auth          *service.AuthService
+	passwords     *service.PasswordService
+	verifications *service.VerificationService
+	cfg           config.Config
 }
 
-func NewUserHandler(users *service.UserService) *UserHandler {
-	return &UserHandler{users: users}
+func NewUserHandler(users *service.UserService, auth *service.AuthService, passwords *service.PasswordService, verifications *service.VerificationService, cfg config.Config) *UserHandler {
+	return &UserHandler{users: users, auth: auth, passwords: passwords, verifications: verifications, cfg: cfg}
 }
 
 func (h *UserHandler) Register(router fiber.Router) {
 	router.Post("/", h.Create)
 	router.Get("/", middleware.RequireAuth(), h.Me)
+	router.Patch("/me", middleware.RequireAuth(), middleware.RequireSession(), h.Update)
+	router.Delete("/me", middleware.RequireAuth(), middleware.RequireSession(), h.Delete)
+	router.Put("/me/password", middleware.RequireAuth(), middleware.RequireSession(), h.ChangePassword)
+	router.Post("/me/verification", middleware.RequireAuth(), middleware.RequireSession(), h.ResendVerification)
+	router.Post("/verification", h.RequestVerification)
+	router.Post("/verify", h.Verify)
 }
 
 func (h *UserHandler) Create(c *fiber.Ctx) error {
@@ -35,6 +49,11 @@ func (h *UserHandler) Create(c *fiber.Ctx) error {
 		return err
 	}
 
+	// The account exists already; a mail failure is recovered with a resend.
+	if err := h.verifications.Send(c.UserContext(), user); err != nil {
+		log.Printf("verification email for user %s: %v", user.UserID, err)
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(response.Success(newUserResponse(user)))
 }
 
@@ -52,18 +71,146 @@ func (h *UserHandler) Me(c *fiber.Ctx) error {
 	return c.JSON(response.Success(newUserResponse(user)))
 }
 
+// Update changes the profile. A new email is unverified until the link sent to it is opened.
+func (h *UserHandler) Update(c *fiber.Ctx) error {
+	var payload service.UpdateUserInput
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	user, emailChanged, err := h.users.Update(c.UserContext(), middleware.UserID(c), payload)
+	if err != nil {
+		return err
+	}
+
+	if emailChanged {
+		if err := h.verifications.Send(c.UserContext(), user); err != nil {
+			log.Printf("verification email for user %s: %v", user.UserID, err)
+		}
+	}
+
+	return c.JSON(response.Success(newUserResponse(user)))
+}
+
+// Delete permanently removes the account and ends all of its sessions.
+func (h *UserHandler) Delete(c *fiber.Ctx) error {
+	var payload service.DeleteUserInput
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	userID := middleware.UserID(c)
+	if err := h.users.Delete(c.UserContext(), userID, payload); err != nil {
+		return err
+	}
+
+	// The account is gone already; sessions left behind by a Redis failure no longer find their user.
+	if err := h.auth.LogoutEverywhere(c.UserContext(), userID, ""); err != nil {
+		log.Printf("sessions of deleted user %s: %v", userID, err)
+	}
+
+	clearSessionCookie(c, h.cfg)
+
+	return c.JSON(response.Success(nil))
+}
+
+// ChangePassword sets a new password and logs out every other session.
+func (h *UserHandler) ChangePassword(c *fiber.Ctx) error {
+	var payload service.ChangePasswordInput
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	if err := h.passwords.Change(c.UserContext(), middleware.UserID(c), middleware.SessionID(c), payload); err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+func (h *UserHandler) ResendVerification(c *fiber.Ctx) error {
+	user, err := h.users.GetByID(c.UserContext(), middleware.UserID(c))
+	if err != nil {
+		return err
+	}
+
+	if err := h.verifications.Send(c.UserContext(), user); err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+type requestVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// RequestVerification resends the verification link to users who cannot log in yet. It always succeeds so it cannot
+// be used to find out which emails are registered; Send still throttles the emails per user and address.
+func (h *UserHandler) RequestVerification(c *fiber.Ctx) error {
+	var payload requestVerificationRequest
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	payload.Email = strings.TrimSpace(payload.Email)
+	if payload.Email == "" {
+		return apperror.New(apperror.ServerParamsMissing, "Email is required")
+	}
+
+	user, err := h.users.FindByEmail(c.UserContext(), payload.Email)
+	if err != nil {
+		if apperror.Is(err, apperror.UserNotFound) {
+			return c.JSON(response.Success(nil))
+		}
+
+		return err
+	}
+
+	if err := h.verifications.Send(c.UserContext(), user); err != nil && !apperror.Is(err, apperror.UserVerificationThrottled) {
+		log.Printf("verification email for user %s: %v", user.UserID, err)
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func (h *UserHandler) Verify(c *fiber.Ctx) error {
+	var payload verifyEmailRequest
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+
+	if payload.Token == "" {
+		return apperror.New(apperror.ServerParamsMissing, "Token is required")
+	}
+
+	if err := h.verifications.Verify(c.UserContext(), payload.Token); err != nil {
+		return err
+	}
+
+	return c.JSON(response.Success(nil))
+}
+
 type userResponse struct {
-	UserID    string `json:"userId"`
-	Email     string `json:"email"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	UserID           string `json:"userId"`
+	Email            string `json:"email"`
+	FirstName        string `json:"firstName"`
+	LastName         string `json:"lastName"`
+	EmailVerified    bool   `json:"emailVerified"`
+	TwoFactorEnabled bool   `json:"twoFactorEnabled"`
 }
 
 func newUserResponse(user *models.User) userResponse {
 	return userResponse{
-		UserID:    user.UserID,
-		Email:     user.Email,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
+		UserID:           user.UserID,
+		Email:            user.Email,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		EmailVerified:    user.EmailVerifiedAt != nil,
+		TwoFactorEnabled: user.TOTPEnabledAt != nil,
 	}
 }
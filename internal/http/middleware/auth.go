@@ -1,18 +1,35 @@
 package middleware
 
 import (
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/iperez/new-expenses-go/internal/domain/models"
 	"github.com/iperez/new-expenses-go/internal/service"
 	"github.com/iperez/new-expenses-go/pkg/apperror"
 )
 
 const userIDKey = "userID"
 const sessionIDKey = "sessionID"
+const tokenScopeKey = "tokenScope"
 
-// Session attaches the userId (if authenticated) to the Fiber context.
-func Session(auth *service.AuthService, cookieName string) fiber.Handler {
+// Session attaches the userId (if authenticated) to the Fiber context. Requests carrying an
+// `Authorization: Bearer <token>` header are authenticated with the API token instead of the cookie.
+func Session(auth *service.AuthService, tokens *service.APITokenService, cookieName string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if secret, ok := bearerToken(c); ok {
+			token, err := tokens.Authenticate(c.UserContext(), secret)
+			if err != nil {
+				return err
+			}
+
+			c.Locals(userIDKey, token.UserID)
+			c.Locals(tokenScopeKey, token.Scope)
+
+			return c.Next()
+		}
+
 		sessionID := c.Cookies(cookieName)
 		c.Locals(sessionIDKey, sessionID)
 
@@ -33,13 +50,32 @@ func Session(auth *service.AuthService, cookieName string) fiber.Handler {
 	}
 }
 
-// RequireAuth ensures the request is performed by an authenticated user.
+// RequireAuth ensures the request is performed by an authenticated user. Read-only API tokens are limited to safe
+// methods: the scope is enforced by HTTP method, not per route, so GET and HEAD handlers must never change state.
+// Routes touching credentials or the account add RequireSession instead of relying on the token scope.
 func RequireAuth() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if _, ok := c.Locals(userIDKey).(string); !ok {
 			return apperror.New(apperror.AuthNeedLogin, nil)
 		}
 
+		if scope, ok := c.Locals(tokenScopeKey).(models.TokenScope); ok && scope != models.TokenScopeWrite {
+			if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+				return apperror.New(apperror.AuthTokenScope, nil)
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireSession rejects requests authenticated with an API token, for routes that manage credentials.
+func RequireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals(tokenScopeKey).(models.TokenScope); ok {
+			return apperror.New(apperror.AuthSessionRequired, nil)
+		}
+
 		return c.Next()
 	}
 }
@@ -61,3 +97,12 @@ func SessionID(c *fiber.Ctx) string {
 
 	return ""
 }
+
+func bearerToken(c *fiber.Ctx) (string, bool) {
+	scheme, token, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a background task executed periodically while the server is running.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Start runs every job in its own goroutine until the context is cancelled.
+func Start(ctx context.Context, jobs []Job) {
+	for _, job := range jobs {
+		go loop(ctx, job)
+	}
+}
+
+// loop executes the job right away and then on every tick. Failures are logged and never stop the loop.
+func loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(ctx); err != nil {
+			log.Printf("job %s failed: %v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
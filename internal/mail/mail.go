@@ -0,0 +1,90 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+	"sync"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// SMTPMailer delivers emails through an SMTP server, authenticating with PLAIN auth when a username is set.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	mailer := &SMTPMailer{addr: fmt.Sprintf("%s:%d", host, port), from: from}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return mailer
+}
+
+// Send delivers the message. net/smtp does not support cancellation, so the context is not honored.
+func (m *SMTPMailer) Send(_ context.Context, message Message) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", m.from)
+	fmt.Fprintf(&body, "To: %s\r\n", message.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", message.Subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, []byte(body.String()))
+}
+
+// Outbox keeps the messages in memory instead of delivering them. It is meant for tests.
+type Outbox struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewOutbox() *Outbox {
+	return &Outbox{}
+}
+
+func (o *Outbox) Send(_ context.Context, message Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, message)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far.
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Message(nil), o.messages...)
+}
+
+// LogMailer logs the recipient and subject of the messages. It is used when no SMTP server is configured. Bodies are
+// left out because they carry reset and verification links.
+type LogMailer struct{}
+
+func NewLogMailer() LogMailer {
+	return LogMailer{}
+}
+
+func (LogMailer) Send(_ context.Context, message Message) error {
+	log.Printf("mail to %s: %s (not sent, SMTP_HOST is not set)", message.To, message.Subject)
+	return nil
+}
@@ -1,9 +1,11 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -16,6 +18,9 @@ import (
 	"github.com/iperez/new-expenses-go/internal/domain/models"
 	"github.com/iperez/new-expenses-go/internal/http/handlers"
 	"github.com/iperez/new-expenses-go/internal/http/middleware"
+	"github.com/iperez/new-expenses-go/internal/jobs"
+	"github.com/iperez/new-expenses-go/internal/mail"
+	"github.com/iperez/new-expenses-go/internal/oidc"
 	"github.com/iperez/new-expenses-go/internal/service"
 	"github.com/iperez/new-expenses-go/pkg/apperror"
 	"github.com/iperez/new-expenses-go/pkg/response"
@@ -23,27 +28,86 @@ import (
 
 // Server wires the Fiber HTTP server with the services.
 type Server struct {
-	cfg config.Config
-	app *fiber.App
+	cfg  config.Config
+	app  *fiber.App
+	jobs []jobs.Job
+}
+
+// Option customizes the dependencies New would otherwise build from the configuration.
+type Option func(*options)
+
+type options struct {
+	mailer mail.Mailer
+}
+
+// WithMailer replaces the mailer built from the SMTP settings (e.g. with a mail.Outbox in tests).
+func WithMailer(mailer mail.Mailer) Option {
+	return func(o *options) {
+		o.mailer = mailer
+	}
 }
 
 // New bootstraps the HTTP server with every dependency wired.
-func New(cfg config.Config, db *gorm.DB, redisClient *redis.Client) *Server {
-	for _, model := range []interface{}{&models.User{}, &models.Category{}, &models.Transaction{}} {
+func New(cfg config.Config, db *gorm.DB, redisClient *redis.Client, opts ...Option) *Server {
+	settings := options{}
+	for _, opt := range opts {
+		opt(&settings)
+	}
+
+	if settings.mailer == nil {
+		settings.mailer = mail.NewLogMailer()
+		if cfg.SMTPHost != "" {
+			settings.mailer = mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+		}
+	}
+
+	for _, model := range []interface{}{&models.User{}, &models.Category{}, &models.Transaction{}, &models.CategoryDeletion{}, &models.NetWorthSnapshot{}, &models.APIToken{}, &models.UserIdentity{}} {
 		if err := db.AutoMigrate(model); err != nil {
 			log.Fatalf("failed to run migrations: %v", err)
 		}
 	}
 
-	userService := service.NewUserService(db)
-	categoryService := service.NewCategoryService(db)
+	templates, err := service.LoadCategoryTemplates(cfg.CategoryTemplatesFile)
+	if err != nil {
+		log.Fatalf("failed to load category templates: %v", err)
+	}
+
+	if _, ok := templates[cfg.DefaultCategoryPack]; cfg.DefaultCategoryPack != "" && !ok {
+		log.Fatalf("unknown default category pack %q", cfg.DefaultCategoryPack)
+	}
+
+	categoryService := service.NewCategoryService(db, cfg.CategoryUndoTTL, templates)
+	userService := service.NewUserService(db, categoryService, cfg.DefaultCategoryPack, cfg.DefaultLocale)
+	if err := categoryService.EnsureNameIndex(context.Background()); err != nil {
+		log.Fatalf("categories: %v (run `go run ./cmd/dedupe-categories` to merge duplicates)", err)
+	}
+
 	transactionService := service.NewTransactionService(db, categoryService)
-	authService := service.NewAuthService(userService, redisClient, cfg.SessionTTL)
+	twoFactorService := service.NewTwoFactorService(db, redisClient, cfg.TOTPIssuer)
+	authService := service.NewAuthService(userService, twoFactorService, redisClient, service.SessionOptions{
+		IdleTTL: cfg.SessionTTL,
+		MaxAge:  cfg.SessionMaxAge,
+	}, cfg.RequireVerifiedEmail)
+	passwordService := service.NewPasswordService(userService, authService, redisClient, settings.mailer, cfg.PasswordResetTTL, cfg.AppURL)
+	verificationService := service.NewVerificationService(db, redisClient, settings.mailer, cfg.EmailVerificationTTL, cfg.AppURL)
+	tokenService := service.NewAPITokenService(db)
+	var oidcService *service.OIDCService
+	if cfg.OIDCIssuerURL != "" {
+		provider := oidc.NewProvider(cfg.OIDCIssuerURL, cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCRedirectURL)
+		oidcService = service.NewOIDCService(db, redisClient, provider, userService, authService)
+	}
+	trashService := service.NewTrashService(db)
+	reportService := service.NewReportService(db, transactionService)
 
+	// Client IPs drive the login lockout, so the proxy header is only read from the trusted proxies.
 	app := fiber.New(fiber.Config{
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			return errorHandler(c, err)
 		},
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      true,
 	})
 
 	app.Use(recover.New())
@@ -54,25 +118,71 @@ func New(cfg config.Config, db *gorm.DB, redisClient *redis.Client) *Server {
 		AllowHeaders:     "Content-Type,Authorization",
 	}))
 
-	app.Use(middleware.Session(authService, cfg.SessionCookieName))
+	app.Use(middleware.Session(authService, tokenService, cfg.SessionCookieName))
 
 	api := app.Group("/api")
 
 	handlers.NewHealthHandler().Register(api.Group("/health"))
-	handlers.NewUserHandler(userService).Register(api.Group("/users"))
-	handlers.NewAuthHandler(authService, cfg).Register(api.Group("/auth"))
+	handlers.NewUserHandler(userService, authService, passwordService, verificationService, cfg).Register(api.Group("/users"))
+	handlers.NewAuthHandler(authService, passwordService, oidcService, cfg).Register(api.Group("/auth"))
+	handlers.NewTokenHandler(tokenService).Register(api.Group("/auth/tokens"))
+	handlers.NewTwoFactorHandler(userService, authService, twoFactorService).Register(api.Group("/auth/2fa"))
 	handlers.NewCategoryHandler(categoryService).Register(api.Group("/categories"))
 	handlers.NewTransactionHandler(transactionService).Register(api.Group("/transactions"))
+	handlers.NewTrashHandler(trashService).Register(api.Group("/trash"))
+	handlers.NewReportHandler(reportService).Register(api.Group("/reports"))
 
 	app.Use(func(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(response.Error(apperror.ServerNotFound, nil))
 	})
 
-	return &Server{cfg: cfg, app: app}
+	backgroundJobs := []jobs.Job{
+		{
+			Name:     "trash-purge",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				purged, err := trashService.PurgeExpired(ctx, time.Now().Add(-cfg.TrashRetention))
+				if err == nil && purged > 0 {
+					log.Printf("trash-purge: permanently deleted %d items", purged)
+				}
+				return err
+			},
+		},
+		{
+			Name:     "net-worth-snapshot",
+			Interval: 24 * time.Hour,
+			// Both the month in progress and the previous one are refreshed, so a month keeps being snapshotted after it
+			// closes and its last snapshot covers all of it. Missing past months (e.g. from before the job) are backfilled.
+			Run: func(ctx context.Context) error {
+				current := service.PeriodOf(time.Now())
+				created, err := reportService.BackfillNetWorth(ctx, current.AddMonths(-1))
+				if err != nil {
+					return err
+				}
+				if created > 0 {
+					log.Printf("net-worth-snapshot: backfilled %d snapshots", created)
+				}
+
+				for _, at := range []service.Period{current.AddMonths(-1), current} {
+					if _, err := reportService.SnapshotNetWorth(ctx, at); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	}
+
+	return &Server{cfg: cfg, app: app, jobs: backgroundJobs}
 }
 
-// Start begins listening for HTTP requests.
+// Start launches the background jobs and begins listening for HTTP requests.
 func (s *Server) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jobs.Start(ctx, s.jobs)
+
 	return s.app.Listen(fmt.Sprintf(":%d", s.cfg.Port))
 }
 
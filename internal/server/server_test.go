@@ -2,11 +2,22 @@ package server_test
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,7 +29,10 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/iperez/new-expenses-go/internal/config"
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/internal/mail"
 	"github.com/iperez/new-expenses-go/internal/server"
+	"github.com/iperez/new-expenses-go/internal/service"
 )
 
 func TestExpensesFlow(t *testing.T) {
@@ -40,11 +54,1517 @@ func TestExpensesFlow(t *testing.T) {
 	require.Equal(t, category.CategoryID, *list[0].CategoryID)
 }
 
+func TestLoginRotatesSession(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	user := createUser(t, app)
+
+	planted := &http.Cookie{Name: "sessionID", Value: "attacker-chosen"}
+	body := map[string]string{"email": user.Email, "password": "secret123"}
+
+	resp := doRequest(t, app, http.MethodPost, "/api/auth/login", body, []*http.Cookie{planted})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	sessionCookie := findCookie(resp.Cookies(), "sessionID")
+	require.NotNil(t, sessionCookie)
+	require.NotEqual(t, planted.Value, sessionCookie.Value)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{planted})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Zero(t, redisClient.Exists(context.Background(), "user:"+planted.Value).Val())
+
+	resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Logging in again from an authenticated session replaces it too.
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/login", body, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	rotated := findCookie(resp.Cookies(), "sessionID")
+	require.NotNil(t, rotated)
+	require.NotEqual(t, sessionCookie.Value, rotated.Value)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{rotated})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestSessionManagement(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	user := createUser(t, app)
+
+	laptop := login(t, app, user.Email, "secret123")
+	phone := login(t, app, user.Email, "secret123")
+
+	type session struct {
+		ID       string    `json:"id"`
+		LastSeen time.Time `json:"lastSeen"`
+		Current  bool      `json:"current"`
+	}
+	var sessions []session
+	getData(t, app, laptop, "/api/auth/sessions", &sessions)
+	require.Len(t, sessions, 2)
+
+	var phoneID string
+	for _, item := range sessions {
+		require.False(t, item.LastSeen.IsZero())
+		if !item.Current {
+			phoneID = item.ID
+		}
+	}
+	require.NotEmpty(t, phoneID)
+	require.NotEqual(t, phone.Value, phoneID)
+
+	resp := doRequest(t, app, http.MethodDelete, "/api/auth/sessions/"+phoneID, nil, []*http.Cookie{laptop})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{phone})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/auth/sessions/"+phoneID, nil, []*http.Cookie{laptop})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	tablet := login(t, app, user.Email, "secret123")
+	resp = doRequest(t, app, http.MethodDelete, "/api/auth/sessions", nil, []*http.Cookie{tablet})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, cookie := range []*http.Cookie{laptop, tablet} {
+		resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{cookie})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+	require.Zero(t, redisClient.Exists(context.Background(), "sessions:"+user.UserID).Val())
+}
+
+func TestProxyClientIP(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+	user := createUser(t, server.New(testConfig(), db, redisClient).App())
+
+	sessionIP := func(trustedProxies []string) string {
+		cfg := testConfig()
+		cfg.ProxyHeader = "X-Real-IP"
+		cfg.TrustedProxies = trustedProxies
+		app := server.New(cfg, db, redisClient).App()
+
+		credentials := map[string]string{"email": user.Email, "password": "secret123"}
+		resp := doRequestWithHeader(t, app, http.MethodPost, "/api/auth/login", credentials, nil, http.Header{"X-Real-IP": {"203.0.113.7"}})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var sessions []struct {
+			IP      string `json:"ip"`
+			Current bool   `json:"current"`
+		}
+		getData(t, app, findCookie(resp.Cookies(), "sessionID"), "/api/auth/sessions", &sessions)
+		for _, session := range sessions {
+			if session.Current {
+				return session.IP
+			}
+		}
+
+		t.Fatal("current session not listed")
+		return ""
+	}
+
+	// Test requests come from 0.0.0.0; the header is ignored unless that address is trusted.
+	require.Equal(t, "203.0.113.7", sessionIP([]string{"0.0.0.0"}))
+	require.Equal(t, "0.0.0.0", sessionIP([]string{"10.0.0.0/8"}))
+}
+
+func TestSlidingSessionExpiration(t *testing.T) {
+	db := newTestDB(t)
+	mr, redisClient := newTestRedisServer(t)
+
+	cfg := testConfig()
+	cfg.SessionTTL = time.Hour
+	cfg.SessionMaxAge = 3 * time.Hour
+
+	app := server.New(cfg, db, redisClient).App()
+	user := createUser(t, app)
+
+	active := login(t, app, user.Email, "secret123")
+	idle := login(t, app, user.Email, "secret123")
+	require.Equal(t, int(cfg.SessionMaxAge.Seconds()), active.MaxAge)
+
+	me := func(cookie *http.Cookie) int {
+		return doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{cookie}).StatusCode
+	}
+
+	// Each request extends the idle timeout, so 50 minute gaps never log the active session out.
+	for step := 0; step < 3; step++ {
+		mr.FastForward(50 * time.Minute)
+		require.Equal(t, http.StatusOK, me(active), "step %d", step)
+	}
+	require.Equal(t, http.StatusUnauthorized, me(idle))
+
+	// After 150 minutes only 30 remain before the maximum age, whatever the activity.
+	require.LessOrEqual(t, mr.TTL("user:"+active.Value), 30*time.Minute)
+	mr.FastForward(31 * time.Minute)
+	require.Equal(t, http.StatusUnauthorized, me(active))
+}
+
+func TestAPITokens(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	type token struct {
+		TokenID    string     `json:"tokenId"`
+		Token      string     `json:"token"`
+		Scope      string     `json:"scope"`
+		ExpiresAt  *time.Time `json:"expiresAt"`
+		LastUsedAt *time.Time `json:"lastUsedAt"`
+	}
+	createToken := func(body map[string]interface{}) token {
+		resp := doRequest(t, app, http.MethodPost, "/api/auth/tokens", body, cookies)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var parsed customResponse
+		decodeResponse(t, resp.Body, &parsed)
+
+		var created token
+		require.NoError(t, json.Unmarshal(parsed.Data, &created))
+		require.NotEmpty(t, created.Token)
+
+		return created
+	}
+
+	reader := createToken(map[string]interface{}{"name": "dashboard", "scope": "read", "expiresInDays": 30})
+	writer := createToken(map[string]interface{}{"name": "shortcut", "scope": "WRITE"})
+	require.NotNil(t, reader.ExpiresAt)
+	require.Nil(t, writer.ExpiresAt)
+
+	resp := doBearerRequest(t, app, http.MethodGet, "/api/transactions", nil, reader.Token)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	expense := map[string]interface{}{"type": "EXPENSE", "amount": 10, "currency": "UYU", "month": "MARCH", "year": 2024, "category": map[string]string{"name": "Food"}}
+	resp = doBearerRequest(t, app, http.MethodPost, "/api/transactions", expense, reader.Token)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doBearerRequest(t, app, http.MethodPost, "/api/transactions", expense, writer.Token)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// Tokens cannot mint or list tokens, manage sessions nor send account emails.
+	resp = doBearerRequest(t, app, http.MethodPost, "/api/auth/tokens", map[string]string{"name": "x", "scope": "WRITE"}, writer.Token)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = doBearerRequest(t, app, http.MethodGet, "/api/auth/sessions", nil, writer.Token)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = doBearerRequest(t, app, http.MethodPost, "/api/users/me/verification", nil, writer.Token)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doBearerRequest(t, app, http.MethodGet, "/api/transactions", nil, "nexp_unknown")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	var tokens []token
+	getData(t, app, sessionCookie, "/api/auth/tokens", &tokens)
+	require.Len(t, tokens, 2)
+	for _, listed := range tokens {
+		require.Empty(t, listed.Token)
+		require.NotNil(t, listed.LastUsedAt)
+	}
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/auth/tokens/"+writer.TokenID, nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doBearerRequest(t, app, http.MethodGet, "/api/transactions", nil, writer.Token)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/auth/tokens/"+writer.TokenID, nil, cookies)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Expired tokens are rejected.
+	require.NoError(t, db.Exec("UPDATE api_tokens SET expires_at = ? WHERE token_id = ?", time.Now().Add(-time.Minute), reader.TokenID).Error)
+	resp = doBearerRequest(t, app, http.MethodGet, "/api/transactions", nil, reader.Token)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestChangePassword(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	user := createUser(t, app)
+
+	current := login(t, app, user.Email, "secret123")
+	other := login(t, app, user.Email, "secret123")
+
+	resp := doRequest(t, app, http.MethodPut, "/api/users/me/password", map[string]string{"currentPassword": "wrong", "newPassword": "changed123"}, []*http.Cookie{current})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPut, "/api/users/me/password", map[string]string{"currentPassword": "secret123", "newPassword": "changed123"}, []*http.Cookie{current})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Equal(t, http.StatusOK, doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{current}).StatusCode)
+	require.Equal(t, http.StatusUnauthorized, doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{other}).StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/login", map[string]string{"email": user.Email, "password": "secret123"}, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	login(t, app, user.Email, "changed123")
+}
+
+func TestPasswordReset(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+	outbox := mail.NewOutbox()
+
+	app := server.New(testConfig(), db, redisClient, server.WithMailer(outbox)).App()
+	user := createUser(t, app)
+	sessionCookie := login(t, app, user.Email, "secret123")
+	signupMessages := len(outbox.Messages())
+
+	resp := doRequest(t, app, http.MethodPost, "/api/auth/password/forgot", map[string]string{"email": "nobody@example.com"}, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, outbox.Messages(), signupMessages)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/password/forgot", map[string]string{"email": user.Email}, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	messages := outbox.Messages()[signupMessages:]
+	require.Len(t, messages, 1)
+	require.Equal(t, user.Email, messages[0].To)
+
+	// A second request within the throttle window is accepted but sends nothing.
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/password/forgot", map[string]string{"email": user.Email}, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, outbox.Messages(), signupMessages+1)
+
+	reset := map[string]string{"token": mailToken(t, messages[0], "reset-password"), "newPassword": "recovered1"}
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/password/reset", reset, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/password/reset", reset, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	require.Equal(t, http.StatusUnauthorized, doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{sessionCookie}).StatusCode)
+	login(t, app, user.Email, "recovered1")
+}
+
+func TestPasswordResetSingleLink(t *testing.T) {
+	db := newTestDB(t)
+	mr, redisClient := newTestRedisServer(t)
+	outbox := mail.NewOutbox()
+
+	app := server.New(testConfig(), db, redisClient, server.WithMailer(outbox)).App()
+	user := createUser(t, app)
+
+	requestLink := func() string {
+		mr.FastForward(time.Minute)
+		resp := doRequest(t, app, http.MethodPost, "/api/auth/password/forgot", map[string]string{"email": user.Email}, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		messages := outbox.Messages()
+		return mailToken(t, messages[len(messages)-1], "reset-password")
+	}
+
+	resetWith := func(token, password string) int {
+		return doRequest(t, app, http.MethodPost, "/api/auth/password/reset", map[string]string{"token": token, "newPassword": password}, nil).StatusCode
+	}
+
+	// Only the latest link works, and using it leaves no other link behind.
+	first := requestLink()
+	second := requestLink()
+	require.Equal(t, http.StatusOK, resetWith(second, "recovered1"))
+	require.Equal(t, http.StatusBadRequest, resetWith(first, "hijacked1"))
+
+	// Changing the password revokes the outstanding link.
+	third := requestLink()
+	sessionCookie := login(t, app, user.Email, "recovered1")
+	resp := doRequest(t, app, http.MethodPut, "/api/users/me/password", map[string]string{"currentPassword": "recovered1", "newPassword": "changed123"}, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, http.StatusBadRequest, resetWith(third, "hijacked1"))
+
+	login(t, app, user.Email, "changed123")
+	for _, key := range mr.Keys() {
+		require.False(t, strings.HasPrefix(key, "password-reset:") || strings.HasPrefix(key, "password-reset-user:"), key)
+	}
+}
+
+type failingMailer struct{}
+
+func (failingMailer) Send(context.Context, mail.Message) error {
+	return errors.New("smtp unavailable")
+}
+
+func TestPasswordResetMailFailure(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient, server.WithMailer(failingMailer{})).App()
+	user := createUser(t, app)
+
+	// Mail failures would otherwise tell registered emails apart from unknown ones.
+	resp := doRequest(t, app, http.MethodPost, "/api/auth/password/forgot", map[string]string{"email": user.Email}, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestEmailVerification(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+	outbox := mail.NewOutbox()
+
+	cfg := testConfig()
+	cfg.RequireVerifiedEmail = true
+
+	app := server.New(cfg, db, redisClient, server.WithMailer(outbox)).App()
+	user := createUser(t, app)
+
+	messages := outbox.Messages()
+	require.Len(t, messages, 1)
+
+	credentials := map[string]string{"email": user.Email, "password": "secret123"}
+	resp := doRequest(t, app, http.MethodPost, "/api/auth/login", credentials, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/users/verify", map[string]string{"token": "made-up"}, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	verify := map[string]string{"token": mailToken(t, messages[0], "verify-email")}
+	resp = doRequest(t, app, http.MethodPost, "/api/users/verify", verify, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/users/verify", verify, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	sessionCookie := login(t, app, user.Email, "secret123")
+
+	var me struct {
+		EmailVerified bool `json:"emailVerified"`
+	}
+	getData(t, app, sessionCookie, "/api/users", &me)
+	require.True(t, me.EmailVerified)
+}
+
+func TestResendVerification(t *testing.T) {
+	db := newTestDB(t)
+	mr, redisClient := newTestRedisServer(t)
+	outbox := mail.NewOutbox()
+
+	app := server.New(testConfig(), db, redisClient, server.WithMailer(outbox)).App()
+	user := createUser(t, app)
+	sessionCookie := login(t, app, user.Email, "secret123")
+
+	resp := doRequest(t, app, http.MethodPost, "/api/users/me/verification", nil, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	mr.FastForward(time.Minute)
+	resp = doRequest(t, app, http.MethodPost, "/api/users/me/verification", nil, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, outbox.Messages(), 2)
+}
+
+func TestRequestVerificationByEmail(t *testing.T) {
+	db := newTestDB(t)
+	mr, redisClient := newTestRedisServer(t)
+	outbox := mail.NewOutbox()
+
+	cfg := testConfig()
+	cfg.RequireVerifiedEmail = true
+
+	app := server.New(cfg, db, redisClient, server.WithMailer(outbox)).App()
+	user := createUser(t, app)
+
+	// Unknown emails and throttled requests look like any other request.
+	resp := doRequest(t, app, http.MethodPost, "/api/users/verification", map[string]string{"email": "ghost@example.com"}, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/users/verification", map[string]string{"email": user.Email}, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, outbox.Messages(), 1)
+
+	mr.FastForward(time.Minute)
+	resp = doRequest(t, app, http.MethodPost, "/api/users/verification", map[string]string{"email": "DEMO@example.com"}, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	messages := outbox.Messages()
+	require.Len(t, messages, 2)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/users/verify", map[string]string{"token": mailToken(t, messages[1], "verify-email")}, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	login(t, app, user.Email, "secret123")
+}
+
+func TestLoginBruteForceProtection(t *testing.T) {
+	db := newTestDB(t)
+	mr, redisClient := newTestRedisServer(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	user := createUser(t, app)
+
+	attempt := func(email, password string) *http.Response {
+		return doRequest(t, app, http.MethodPost, "/api/auth/login", map[string]string{"email": email, "password": password}, nil)
+	}
+
+	// Unknown accounts fail exactly like wrong passwords.
+	resp := attempt("ghost@example.com", "secret123")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusBadRequest, attempt(user.Email, "wrong-password").StatusCode)
+	}
+
+	resp = attempt(user.Email, "secret123")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	var parsed struct {
+		Data struct {
+			RetryAfter int `json:"retryAfter"`
+		} `json:"data"`
+	}
+	decodeResponse(t, resp.Body, &parsed)
+	require.InDelta(t, 60, parsed.Data.RetryAfter, 1)
+
+	// A further failure after the lock expires doubles it.
+	mr.FastForward(time.Minute)
+	require.Equal(t, http.StatusBadRequest, attempt(user.Email, "wrong-password").StatusCode)
+	mr.FastForward(time.Minute)
+	require.Equal(t, http.StatusTooManyRequests, attempt(user.Email, "secret123").StatusCode)
+
+	// A successful login resets the account counters.
+	mr.FastForward(time.Minute)
+	require.Equal(t, http.StatusOK, attempt(user.Email, "secret123").StatusCode)
+	require.Equal(t, http.StatusBadRequest, attempt(user.Email, "wrong-password").StatusCode)
+	require.Equal(t, http.StatusOK, attempt(user.Email, "secret123").StatusCode)
+
+	// Spraying many accounts from one IP locks the IP.
+	for i := 0; i < 20; i++ {
+		attempt(fmt.Sprintf("user%d@example.com", i), "guess")
+	}
+	require.Equal(t, http.StatusTooManyRequests, attempt(user.Email, "secret123").StatusCode)
+}
+
+func TestTransactionFilters(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, category := seedTransactions(t, app)
+
+	cases := map[string]int{
+		"/api/transactions?type=INCOME&type=EXPENSE":                   2,
+		"/api/transactions?type=expense":                               1,
+		"/api/transactions?currency=USD,EUR":                           1,
+		"/api/transactions?minAmount=300":                              1,
+		"/api/transactions?minAmount=100&maxAmount=300":                1,
+		"/api/transactions?minAmount=0&maxAmount=0":                    0,
+		"/api/transactions?categoryId=" + category.CategoryID:          1,
+		"/api/transactions?uncategorized=true":                         0,
+		"/api/transactions?uncategorized=true&type=INCOME&type=SAVING": 0,
+	}
+
+	for path, expected := range cases {
+		list := getTransactions(t, app, sessionCookie, path)
+		require.Len(t, list, expected, path)
+	}
+
+	for _, path := range []string{"/api/transactions?minAmount=10&maxAmount=1", "/api/transactions?minAmount=-1"} {
+		resp := doRequest(t, app, http.MethodGet, path, nil, []*http.Cookie{sessionCookie})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+	}
+}
+
+func TestTransactionSort(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, category := seedTransactions(t, app)
+
+	ascending := getTransactions(t, app, sessionCookie, "/api/transactions?sort=amount:asc")
+	require.Len(t, ascending, 2)
+	require.Equal(t, 250.0, ascending[0].Amount)
+
+	byCategory := getTransactions(t, app, sessionCookie, "/api/transactions?sort=category:desc")
+	require.Len(t, byCategory, 2)
+	require.Equal(t, 1000.0, byCategory[0].Amount)
+
+	require.NoError(t, db.Delete(&models.Category{}, "category_id = ?", category.CategoryID).Error)
+	byCategory = getTransactions(t, app, sessionCookie, "/api/transactions?sort=category:desc")
+	require.Equal(t, 250.0, byCategory[0].Amount)
+
+	byDate := getTransactions(t, app, sessionCookie, "/api/transactions?sort=date")
+	require.Equal(t, 250.0, byDate[0].Amount)
+
+	for _, path := range []string{"/api/transactions?sort=password", "/api/transactions?sort=amount:sideways"} {
+		resp := doRequest(t, app, http.MethodGet, path, nil, []*http.Cookie{sessionCookie})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+	}
+}
+
+func TestTrashRestore(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, category := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	list := getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID)
+	require.Len(t, list, 1)
+
+	resp := doRequest(t, app, http.MethodDelete, "/api/categories/"+category.CategoryID+"?deleteTransactions=true", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, listTransactions(t, app, sessionCookie), 1)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/trash", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+
+	var trash struct {
+		Categories   []categoryPayload    `json:"categories"`
+		Transactions []transactionPayload `json:"transactions"`
+	}
+	require.NoError(t, json.Unmarshal(parsed.Data, &trash))
+	require.Len(t, trash.Categories, 1)
+	require.Len(t, trash.Transactions, 1)
+
+	// Restoring the transaction brings its category back as well.
+	resp = doRequest(t, app, http.MethodPost, "/api/trash/"+list[0].TransactionID+"/restore", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, listTransactions(t, app, sessionCookie), 2)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/categories/"+category.CategoryID, nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/trash/"+list[0].TransactionID+"/restore", nil, cookies)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestTrashPurgeCategory(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, category := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	resp := doRequest(t, app, http.MethodDelete, "/api/categories/"+category.CategoryID+"?deleteTransactions=true", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Purging the category takes its trashed transactions along instead of leaving them without a category.
+	resp = doRequest(t, app, http.MethodDelete, "/api/trash/"+category.CategoryID, nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/trash", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+
+	var trash struct {
+		Categories   []categoryPayload    `json:"categories"`
+		Transactions []transactionPayload `json:"transactions"`
+	}
+	require.NoError(t, json.Unmarshal(parsed.Data, &trash))
+	require.Empty(t, trash.Categories)
+	require.Empty(t, trash.Transactions)
+	require.Len(t, listTransactions(t, app, sessionCookie), 1)
+}
+
+func TestTrashRestoreKeepsUndo(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, category := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+	createTransaction(t, app, sessionCookie, category.CategoryID)
+
+	siblings := getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID)
+	require.Len(t, siblings, 2)
+
+	// Restoring one transaction leaves its sibling in the trash, where the undo can still find it.
+	resp := doRequest(t, app, http.MethodDelete, "/api/categories/"+category.CategoryID+"?deleteTransactions=true", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/trash/"+siblings[0].TransactionID+"/restore", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID), 1)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/undo-delete", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID), 2)
+
+	// The same holds when the category itself is restored from the trash.
+	resp = doRequest(t, app, http.MethodDelete, "/api/categories/"+category.CategoryID+"?deleteTransactions=true", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/trash/"+category.CategoryID+"/restore", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID))
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/undo-delete", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID), 2)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/undo-delete", nil, cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// Undoing a later deletion keeps the record of the earlier one.
+	resp = doRequest(t, app, http.MethodDelete, "/api/categories/"+category.CategoryID+"?deleteTransactions=true", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/trash/"+category.CategoryID+"/restore", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	createTransaction(t, app, sessionCookie, category.CategoryID)
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/categories/"+category.CategoryID+"?deleteTransactions=true", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/undo-delete", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID), 1)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/undo-delete", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID), 3)
+}
+
+func TestCategoryUndoDelete(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, category := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	resp := doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/undo-delete", nil, cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/categories/"+category.CategoryID+"?deleteTransactions=true", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, listTransactions(t, app, sessionCookie), 1)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/undo-delete", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID), 1)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/undo-delete", nil, cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestCategoryMerge(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, category := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	duplicate := postCategory(t, app, sessionCookie, map[string]string{"name": "Sueldo", "type": "INCOME"})
+	createTransaction(t, app, sessionCookie, duplicate.CategoryID)
+
+	expense := getTransactions(t, app, sessionCookie, "/api/transactions?type=EXPENSE")
+	require.Len(t, expense, 1)
+	require.NotNil(t, expense[0].CategoryID)
+
+	resp := doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/merge", map[string][]string{"sourceIds": {*expense[0].CategoryID}}, cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/"+category.CategoryID+"/merge", map[string][]string{"sourceIds": {duplicate.CategoryID}}, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+category.CategoryID), 2)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/categories/"+duplicate.CategoryID, nil, cookies)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCategoryMergeIntoDescendant(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	home := postCategory(t, app, sessionCookie, map[string]string{"name": "Home", "type": "EXPENSE"})
+	utilities := postCategory(t, app, sessionCookie, map[string]string{"name": "Utilities", "type": "EXPENSE", "parentId": home.CategoryID})
+	electricity := postCategory(t, app, sessionCookie, map[string]string{"name": "Electricity", "type": "EXPENSE", "parentId": utilities.CategoryID})
+
+	// Merging a grandparent into its grandchild must not leave Utilities and Electricity pointing at each other.
+	resp := doRequest(t, app, http.MethodPost, "/api/categories/"+electricity.CategoryID+"/merge", map[string][]string{"sourceIds": {home.CategoryID}}, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Nil(t, categoryParent(t, db, electricity.CategoryID))
+	require.Equal(t, &electricity.CategoryID, categoryParent(t, db, utilities.CategoryID))
+
+	resp = doRequest(t, app, http.MethodGet, "/api/categories?tree=true", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+
+	type node struct {
+		CategoryID string `json:"categoryId"`
+		Children   []node `json:"children"`
+	}
+	var roots []node
+	require.NoError(t, json.Unmarshal(parsed.Data, &roots))
+
+	var electricityNode *node
+	for idx := range roots {
+		if roots[idx].CategoryID == electricity.CategoryID {
+			electricityNode = &roots[idx]
+		}
+	}
+	require.NotNil(t, electricityNode)
+	require.Len(t, electricityNode.Children, 1)
+	require.Equal(t, utilities.CategoryID, electricityNode.Children[0].CategoryID)
+}
+
+func TestInlineCategoryDeduplication(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	transaction := map[string]interface{}{
+		"type":     "EXPENSE",
+		"amount":   10,
+		"currency": "UYU",
+		"month":    "MARCH",
+		"year":     2024,
+	}
+	first, second := map[string]interface{}{}, map[string]interface{}{}
+	for key, value := range transaction {
+		first[key], second[key] = value, value
+	}
+	first["category"] = map[string]string{"name": "FOOD"}
+	second["category"] = map[string]string{"name": " Fóod "}
+
+	resp := doRequest(t, app, http.MethodPost, "/api/transactions", map[string]interface{}{"transactions": []interface{}{first, second}}, cookies)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	expenses := getTransactions(t, app, sessionCookie, "/api/transactions?type=EXPENSE")
+	require.Len(t, expenses, 3)
+	for _, expense := range expenses {
+		require.Equal(t, *expenses[0].CategoryID, *expense.CategoryID)
+	}
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories", map[string]string{"name": "salary", "type": "INCOME"}, cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestCategoryHierarchy(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	home := postCategory(t, app, sessionCookie, map[string]string{"name": "Home", "type": "EXPENSE"})
+	utilities := postCategory(t, app, sessionCookie, map[string]string{"name": "Utilities", "type": "EXPENSE", "parentId": home.CategoryID})
+	electricity := postCategory(t, app, sessionCookie, map[string]string{"name": "Electricity", "type": "EXPENSE", "parentId": utilities.CategoryID})
+
+	resp := doRequest(t, app, http.MethodPost, "/api/categories", map[string]string{"name": "Bonus", "type": "INCOME", "parentId": home.CategoryID}, cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPatch, "/api/categories/"+home.CategoryID, map[string]string{"parentId": electricity.CategoryID}, cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// Names are unique across the whole tree so inline categories resolve to a single one.
+	resp = doRequest(t, app, http.MethodPost, "/api/categories", map[string]string{"name": "utilities", "type": "EXPENSE", "parentId": electricity.CategoryID}, cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories", map[string]string{"name": "Utilities", "type": "EXPENSE"}, cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	body := map[string]interface{}{
+		"type":       "EXPENSE",
+		"amount":     100,
+		"currency":   "UYU",
+		"month":      "MARCH",
+		"year":       2024,
+		"categoryId": electricity.CategoryID,
+	}
+	resp = doRequest(t, app, http.MethodPost, "/api/transactions", body, cookies)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	require.Empty(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+home.CategoryID))
+	require.Len(t, getTransactions(t, app, sessionCookie, "/api/transactions?includeSubcategories=true&categoryId="+home.CategoryID), 1)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/categories?tree=true", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+
+	type node struct {
+		CategoryID string `json:"categoryId"`
+		Children   []node `json:"children"`
+	}
+	var roots []node
+	require.NoError(t, json.Unmarshal(parsed.Data, &roots))
+
+	var homeNode *node
+	for idx := range roots {
+		if roots[idx].CategoryID == home.CategoryID {
+			homeNode = &roots[idx]
+		}
+	}
+	require.NotNil(t, homeNode)
+	require.Len(t, homeNode.Children, 1)
+	require.Equal(t, electricity.CategoryID, homeNode.Children[0].Children[0].CategoryID)
+
+	// Deleting the middle level lifts its children, and undoing the deletion puts them back.
+	resp = doRequest(t, app, http.MethodDelete, "/api/categories/"+utilities.CategoryID, nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, &home.CategoryID, categoryParent(t, db, electricity.CategoryID))
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/"+utilities.CategoryID+"/undo-delete", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, &utilities.CategoryID, categoryParent(t, db, electricity.CategoryID))
+
+	// Detaching the middle level turns it into a root again.
+	resp = doRequest(t, app, http.MethodPatch, "/api/categories/"+utilities.CategoryID, map[string]interface{}{"parentId": nil}, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, getTransactions(t, app, sessionCookie, "/api/transactions?includeSubcategories=true&categoryId="+home.CategoryID))
+}
+
+func TestDefaultCategoryTemplates(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	cfg := testConfig()
+	cfg.DefaultCategoryPack = "basic"
+	cfg.DefaultLocale = "EN"
+
+	app := server.New(cfg, db, redisClient).App()
+
+	body := map[string]string{
+		"email":     "ana@example.com",
+		"firstName": "Ana",
+		"lastName":  "Pérez",
+		"password":  "secret123",
+		"locale":    "es",
+	}
+	resp := doRequest(t, app, http.MethodPost, "/api/users", body, nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	sessionCookie := login(t, app, body["email"], body["password"])
+	seeded := listCategories(t, app, sessionCookie)
+	require.Len(t, seeded, len(service.DefaultCategoryTemplates()["basic"]))
+	require.Contains(t, categoryNames(seeded), "Sueldo")
+
+	// Seeded names are taken like any other category.
+	resp = doRequest(t, app, http.MethodPost, "/api/categories", map[string]string{"name": "sueldo", "type": "INCOME"}, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/templates/family?locale=ES", nil, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Applying a pack twice reuses the categories it already created.
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/templates/family?locale=ES", nil, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, listCategories(t, app, sessionCookie), len(seeded)+len(service.DefaultCategoryTemplates()["family"]))
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories/templates/unknown", nil, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCategoryOrderingAndArchive(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, salary := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	rent := postCategory(t, app, sessionCookie, map[string]string{"name": "Rent", "type": "EXPENSE", "color": "#ff8800", "icon": "house"})
+	require.Equal(t, []string{"Rent", "Food", "Salary"}, categoryNames(listCategories(t, app, sessionCookie)))
+
+	resp := doRequest(t, app, http.MethodPut, "/api/categories/order", map[string][]string{"categoryIds": {rent.CategoryID, salary.CategoryID}}, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"Rent", "Salary", "Food"}, categoryNames(listCategories(t, app, sessionCookie)))
+
+	resp = doRequest(t, app, http.MethodPatch, "/api/categories/"+salary.CategoryID, map[string]bool{"archived": true}, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"Rent", "Food"}, categoryNames(listCategories(t, app, sessionCookie)))
+	require.Len(t, listCategoriesAt(t, app, sessionCookie, "/api/categories?archived=true"), 3)
+
+	// Archived categories keep their history and can still be used.
+	createTransaction(t, app, sessionCookie, salary.CategoryID)
+	require.Len(t, getTransactions(t, app, sessionCookie, "/api/transactions?categoryId="+salary.CategoryID), 2)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/categories", map[string]string{"name": "Gym", "type": "EXPENSE", "color": "orange"}, cookies)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCategoryPositionsWithLegacyRows(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	postCategory(t, app, sessionCookie, map[string]string{"name": "Rent", "type": "EXPENSE"})
+
+	// Categories created before positions existed all sit at 0 and are listed newest first.
+	base := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for idx, name := range []string{"Salary", "Food", "Rent"} {
+		require.NoError(t, db.Model(&models.Category{}).Where("name = ?", name).
+			Updates(map[string]interface{}{"position": 0, "created_at": base.AddDate(0, idx, 0)}).Error)
+	}
+	require.Equal(t, []string{"Rent", "Food", "Salary"}, categoryNames(listCategories(t, app, sessionCookie)))
+
+	// New categories keep that order instead of landing after them.
+	postCategory(t, app, sessionCookie, map[string]string{"name": "Gym", "type": "EXPENSE"})
+	require.Equal(t, []string{"Gym", "Rent", "Food", "Salary"}, categoryNames(listCategories(t, app, sessionCookie)))
+}
+
+func TestCategoryStats(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, salary := seedTransactions(t, app)
+
+	now := time.Now()
+	body := map[string]interface{}{
+		"type":       "INCOME",
+		"amount":     500,
+		"currency":   "UYU",
+		"month":      strings.ToUpper(now.Month().String()),
+		"year":       now.Year(),
+		"categoryId": salary.CategoryID,
+	}
+	resp := doRequest(t, app, http.MethodPost, "/api/transactions", body, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/categories/"+salary.CategoryID+"/stats?months=6", nil, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+
+	var stats struct {
+		TransactionCount int `json:"transactionCount"`
+		Totals           struct {
+			Total float64 `json:"total"`
+			UYU   float64 `json:"uyu"`
+			USD   float64 `json:"usd"`
+		} `json:"totals"`
+		Monthly []struct {
+			Total float64 `json:"total"`
+		} `json:"monthly"`
+		AveragePerMonth  float64 `json:"averagePerMonth"`
+		FirstTransaction struct {
+			Year  int    `json:"year"`
+			Month string `json:"month"`
+		} `json:"firstTransaction"`
+	}
+	require.NoError(t, json.Unmarshal(parsed.Data, &stats))
+
+	require.Equal(t, 2, stats.TransactionCount)
+	require.Equal(t, 40500.0, stats.Totals.Total)
+	require.Equal(t, 1000.0, stats.Totals.USD)
+	require.Len(t, stats.Monthly, 6)
+	require.Equal(t, 500.0, stats.Monthly[5].Total)
+	require.Equal(t, round2(500.0/6), stats.AveragePerMonth)
+	require.Equal(t, 2024, stats.FirstTransaction.Year)
+	require.Equal(t, "JANUARY", stats.FirstTransaction.Month)
+}
+
+func TestMonthlyReport(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+
+	var entries []struct {
+		Year     int     `json:"year"`
+		Month    string  `json:"month"`
+		Incomes  float64 `json:"incomes"`
+		Expenses float64 `json:"expenses"`
+		Net      float64 `json:"net"`
+	}
+	getData(t, app, sessionCookie, "/api/reports/monthly?from=2023-12&to=2024-03", &entries)
+
+	require.Len(t, entries, 4)
+	require.Equal(t, 2023, entries[0].Year)
+	require.Zero(t, entries[0].Net)
+	require.Equal(t, "JANUARY", entries[1].Month)
+	require.Equal(t, 40000.0, entries[1].Incomes)
+	require.Equal(t, 250.0, entries[2].Expenses)
+	require.Equal(t, -250.0, entries[2].Net)
+	require.Zero(t, entries[3].Net)
+
+	resp := doRequest(t, app, http.MethodGet, "/api/reports/monthly?from=2024-03&to=2024-01", nil, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCategoryBreakdownReport(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	home := postCategory(t, app, sessionCookie, map[string]string{"name": "Home", "type": "EXPENSE"})
+	electricity := postCategory(t, app, sessionCookie, map[string]string{"name": "Electricity", "type": "EXPENSE", "parentId": home.CategoryID})
+	misc := postCategory(t, app, sessionCookie, map[string]string{"name": "Misc", "type": "EXPENSE"})
+
+	for _, body := range []map[string]interface{}{
+		{"type": "EXPENSE", "amount": 100, "currency": "UYU", "month": "MARCH", "year": 2024, "categoryId": electricity.CategoryID},
+		{"type": "EXPENSE", "amount": 50, "currency": "UYU", "month": "MARCH", "year": 2024, "categoryId": misc.CategoryID},
+	} {
+		resp := doRequest(t, app, http.MethodPost, "/api/transactions", body, cookies)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	// Leave the Misc transaction without category, as purging its category would.
+	require.NoError(t, db.Exec("UPDATE transactions SET category_id = NULL WHERE category_id = ?", misc.CategoryID).Error)
+
+	type share struct {
+		CategoryID *string `json:"categoryId"`
+		Name       string  `json:"name"`
+		Bucket     string  `json:"bucket"`
+		Total      float64 `json:"total"`
+		Share      float64 `json:"share"`
+		Count      int64   `json:"count"`
+	}
+	var breakdown struct {
+		Total      float64 `json:"total"`
+		Count      int64   `json:"count"`
+		Categories []share `json:"categories"`
+	}
+
+	getData(t, app, sessionCookie, "/api/reports/categories", &breakdown)
+	require.Equal(t, 400.0, breakdown.Total)
+	require.EqualValues(t, 3, breakdown.Count)
+	require.Len(t, breakdown.Categories, 3)
+	require.Equal(t, "Food", breakdown.Categories[0].Name)
+	require.Equal(t, 62.5, breakdown.Categories[0].Share)
+	require.Equal(t, electricity.CategoryID, *breakdown.Categories[1].CategoryID)
+	require.Equal(t, service.BucketUncategorized, breakdown.Categories[2].Bucket)
+	require.Nil(t, breakdown.Categories[2].CategoryID)
+	require.Equal(t, 12.5, breakdown.Categories[2].Share)
+
+	getData(t, app, sessionCookie, "/api/reports/categories?rollup=true&top=1", &breakdown)
+	require.Len(t, breakdown.Categories, 2)
+	require.Equal(t, service.BucketOther, breakdown.Categories[1].Bucket)
+	require.Equal(t, 150.0, breakdown.Categories[1].Total)
+	require.EqualValues(t, 2, breakdown.Categories[1].Count)
+
+	getData(t, app, sessionCookie, "/api/reports/categories?rollup=true", &breakdown)
+	require.Equal(t, home.CategoryID, *breakdown.Categories[1].CategoryID)
+	require.Equal(t, "Home", breakdown.Categories[1].Name)
+
+	getData(t, app, sessionCookie, "/api/reports/categories?month=FEBRUARY&year=2024", &breakdown)
+	require.Len(t, breakdown.Categories, 1)
+	require.Equal(t, 100.0, breakdown.Categories[0].Share)
+
+	getData(t, app, sessionCookie, "/api/reports/categories?type=INCOME", &breakdown)
+	require.Equal(t, "Salary", breakdown.Categories[0].Name)
+	require.Equal(t, 40000.0, breakdown.Total)
+}
+
+func TestCompareReport(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	for _, body := range []map[string]interface{}{
+		{"type": "EXPENSE", "amount": 100, "currency": "UYU", "month": "JANUARY", "year": 2024, "category": map[string]string{"name": "Food"}},
+		{"type": "EXPENSE", "amount": 80, "currency": "UYU", "month": "JANUARY", "year": 2024, "category": map[string]string{"name": "Gym"}},
+		{"type": "EXPENSE", "amount": 300, "currency": "UYU", "month": "FEBRUARY", "year": 2024, "category": map[string]string{"name": "Rent"}},
+	} {
+		resp := doRequest(t, app, http.MethodPost, "/api/transactions", body, cookies)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	type change struct {
+		Type        string   `json:"type"`
+		Name        string   `json:"name"`
+		Current     float64  `json:"current"`
+		Previous    float64  `json:"previous"`
+		Delta       float64  `json:"delta"`
+		Percent     *float64 `json:"percent"`
+		Appeared    bool     `json:"appeared"`
+		Disappeared bool     `json:"disappeared"`
+	}
+	var comparison struct {
+		Previous struct {
+			Month string `json:"month"`
+			Year  int    `json:"year"`
+		} `json:"previous"`
+		Types      []change `json:"types"`
+		Categories []change `json:"categories"`
+	}
+
+	getData(t, app, sessionCookie, "/api/reports/compare?month=FEBRUARY&year=2024", &comparison)
+	require.Equal(t, "JANUARY", comparison.Previous.Month)
+	require.Len(t, comparison.Types, 4)
+	require.Equal(t, "INCOME", comparison.Types[0].Type)
+	require.Equal(t, -40000.0, comparison.Types[0].Delta)
+	require.Equal(t, -100.0, *comparison.Types[0].Percent)
+	require.Equal(t, 550.0, comparison.Types[1].Current)
+	require.Equal(t, 180.0, comparison.Types[1].Previous)
+
+	require.Len(t, comparison.Categories, 3)
+	require.Equal(t, "Rent", comparison.Categories[0].Name)
+	require.True(t, comparison.Categories[0].Appeared)
+	require.Nil(t, comparison.Categories[0].Percent)
+	require.Equal(t, "Food", comparison.Categories[1].Name)
+	require.Equal(t, 150.0, *comparison.Categories[1].Percent)
+	require.Equal(t, "Gym", comparison.Categories[2].Name)
+	require.True(t, comparison.Categories[2].Disappeared)
+	require.Equal(t, -80.0, comparison.Categories[2].Delta)
+
+	getData(t, app, sessionCookie, "/api/reports/compare?month=FEBRUARY&year=2024&compareYear=2023", &comparison)
+	require.Equal(t, "FEBRUARY", comparison.Previous.Month)
+	require.Equal(t, 2023, comparison.Previous.Year)
+	require.Zero(t, comparison.Types[1].Previous)
+
+	resp := doRequest(t, app, http.MethodGet, "/api/reports/compare?month=FEBRUARY", nil, cookies)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestForecastReport(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	for _, body := range []map[string]interface{}{
+		{"type": "EXPENSE", "amount": 50, "currency": "UYU", "day": 5, "month": "MARCH", "year": 2024, "category": map[string]string{"name": "Food"}},
+		{"type": "EXPENSE", "amount": 300, "currency": "UYU", "day": 25, "month": "MARCH", "year": 2024, "category": map[string]string{"name": "Rent"}},
+		{"type": "INSTALLMENTS", "amount": 200, "currency": "UYU", "day": 2, "month": "MARCH", "year": 2024, "category": map[string]string{"name": "Card"}},
+	} {
+		resp := doRequest(t, app, http.MethodPost, "/api/transactions", body, cookies)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	type entry struct {
+		Name              string  `json:"name"`
+		SpentToDate       float64 `json:"spentToDate"`
+		Scheduled         float64 `json:"scheduled"`
+		RunRate           float64 `json:"runRate"`
+		HistoricalAverage float64 `json:"historicalAverage"`
+		Projected         float64 `json:"projected"`
+	}
+	var forecast struct {
+		Month  string `json:"month"`
+		Totals struct {
+			SpentToDate float64 `json:"spentToDate"`
+			Scheduled   float64 `json:"scheduled"`
+			Projected   float64 `json:"projected"`
+		} `json:"totals"`
+		Categories []entry `json:"categories"`
+	}
+
+	getData(t, app, sessionCookie, "/api/reports/forecast?date=2024-03-10&months=2", &forecast)
+	require.Equal(t, "MARCH", forecast.Month)
+	require.Len(t, forecast.Categories, 3)
+
+	byName := make(map[string]entry)
+	for _, category := range forecast.Categories {
+		byName[category.Name] = category
+	}
+
+	food := byName["Food"]
+	require.Equal(t, 50.0, food.SpentToDate)
+	require.Equal(t, 155.0, food.RunRate)
+	require.Equal(t, 125.0, food.HistoricalAverage)
+	require.Equal(t, 134.68, food.Projected)
+
+	require.Equal(t, 300.0, byName["Rent"].Scheduled)
+	require.Equal(t, 300.0, byName["Rent"].Projected)
+	require.Equal(t, 200.0, byName["Card"].Projected)
+
+	require.Equal(t, 50.0, forecast.Totals.SpentToDate)
+	require.Equal(t, 500.0, forecast.Totals.Scheduled)
+	require.Equal(t, 634.68, forecast.Totals.Projected)
+
+	resp := doRequest(t, app, http.MethodGet, "/api/reports/forecast?date=10-03-2024", nil, cookies)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestNetWorth(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	cookies := []*http.Cookie{sessionCookie}
+
+	for _, body := range []map[string]interface{}{
+		{"type": "SAVING", "amount": 1000, "currency": "UYU", "month": "FEBRUARY", "year": 2024, "category": map[string]string{"name": "Savings"}},
+		{"type": "INSTALLMENTS", "amount": 300, "currency": "UYU", "month": "MARCH", "year": 2024, "category": map[string]string{"name": "Card"}},
+		{"type": "INSTALLMENTS", "amount": 300, "currency": "UYU", "month": "APRIL", "year": 2024, "category": map[string]string{"name": "Card"}},
+	} {
+		resp := doRequest(t, app, http.MethodPost, "/api/transactions", body, cookies)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	type netWorth struct {
+		Month       string  `json:"month"`
+		Cash        float64 `json:"cash"`
+		Savings     float64 `json:"savings"`
+		Assets      float64 `json:"assets"`
+		Liabilities float64 `json:"liabilities"`
+		NetWorth    float64 `json:"netWorth"`
+	}
+
+	var current netWorth
+	getData(t, app, sessionCookie, "/api/reports/net-worth?at=2024-02", &current)
+	require.Equal(t, 38750.0, current.Cash)
+	require.Equal(t, 1000.0, current.Savings)
+	require.Equal(t, 39750.0, current.Assets)
+	require.Equal(t, 600.0, current.Liabilities)
+	require.Equal(t, 39150.0, current.NetWorth)
+
+	reports := service.NewReportService(db, service.NewTransactionService(db, service.NewCategoryService(db, 0, nil)))
+	for _, at := range []service.Period{{Year: 2024, Month: "MARCH"}, {Year: 2024, Month: "FEBRUARY"}, {Year: 2024, Month: "MARCH"}} {
+		_, err := reports.SnapshotNetWorth(context.Background(), at)
+		require.NoError(t, err)
+	}
+
+	var history []netWorth
+	getData(t, app, sessionCookie, "/api/reports/net-worth/history?from=2024-01&to=2024-06", &history)
+	require.Len(t, history, 2)
+	require.Equal(t, "FEBRUARY", history[0].Month)
+	require.Equal(t, 39150.0, history[0].NetWorth)
+	require.Equal(t, "MARCH", history[1].Month)
+	require.Equal(t, 38450.0, history[1].Cash)
+	require.Equal(t, 300.0, history[1].Liabilities)
+
+	// Backfilling fills the months missing since the first transaction and leaves stored ones alone.
+	created, err := reports.BackfillNetWorth(context.Background(), service.Period{Year: 2024, Month: "JUNE"})
+	require.NoError(t, err)
+	require.Equal(t, 4, created)
+
+	created, err = reports.BackfillNetWorth(context.Background(), service.Period{Year: 2024, Month: "JUNE"})
+	require.NoError(t, err)
+	require.Zero(t, created)
+
+	getData(t, app, sessionCookie, "/api/reports/net-worth/history?from=2024-01&to=2024-06", &history)
+	require.Len(t, history, 6)
+	require.Equal(t, "JANUARY", history[0].Month)
+	require.Equal(t, "JUNE", history[5].Month)
+	require.Equal(t, 39150.0, history[5].NetWorth)
+}
+
+// mailToken extracts the token of the link to the given frontend path from an email.
+// testOIDCProvider is a minimal OpenID provider issuing RS256 ID tokens for a single client.
+type testOIDCProvider struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	subject string
+	email   string
+	codes   map[string]url.Values
+}
+
+func newTestOIDCProvider(t *testing.T) *testOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	provider := &testOIDCProvider{key: key, subject: "provider-user-1", codes: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 provider.server.URL,
+			"authorization_endpoint": provider.server.URL + "/authorize",
+			"token_endpoint":         provider.server.URL + "/token",
+			"jwks_uri":               provider.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		code := fmt.Sprintf("code-%d", len(provider.codes))
+		provider.codes[code] = r.URL.Query()
+
+		redirect, _ := url.Parse(r.URL.Query().Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {code}, "state": {r.URL.Query().Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		authorized, ok := provider.codes[r.PostFormValue("code")]
+		delete(provider.codes, r.PostFormValue("code"))
+
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || clientID != "expenses" || secret != "client-secret" ||
+			authorized.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(verifier[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     provider.idToken(t, authorized.Get("nonce")),
+		})
+	})
+
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+
+	return provider
+}
+
+func (p *testOIDCProvider) idToken(t *testing.T, nonce string) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	require.NoError(t, err)
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":            p.server.URL,
+		"sub":            p.subject,
+		"aud":            "expenses",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          p.email,
+		"email_verified": true,
+	})
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize plays the user approving the login at the provider and returns the callback path to follow.
+func (p *testOIDCProvider) authorize(t *testing.T, authURL string) string {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	return callback.RequestURI()
+}
+
+// oidcLogin runs a whole OpenID Connect login and returns the callback response.
+func oidcLogin(t *testing.T, app *fiber.App, provider *testOIDCProvider) *http.Response {
+	resp := doRequest(t, app, http.MethodGet, "/api/auth/oidc/login", nil, nil)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	stateCookie := findCookie(resp.Cookies(), "oidcState")
+	require.NotNil(t, stateCookie)
+
+	return doRequest(t, app, http.MethodGet, provider.authorize(t, resp.Header.Get("Location")), nil, []*http.Cookie{stateCookie})
+}
+
+func mailToken(t *testing.T, message mail.Message, path string) string {
+	_, after, found := strings.Cut(message.Body, path+"?token=")
+	require.True(t, found, message.Body)
+
+	token, _, _ := strings.Cut(after, "\n")
+	return token
+}
+
+// getData performs an authenticated GET expecting 200 and decodes the response data into out.
+func getData(t *testing.T, app *fiber.App, cookie *http.Cookie, path string, out interface{}) {
+	resp := doRequest(t, app, http.MethodGet, path, nil, []*http.Cookie{cookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode, path)
+
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+	require.NoError(t, json.Unmarshal(parsed.Data, out))
+}
+
+func categoryParent(t *testing.T, db *gorm.DB, categoryID string) *string {
+	var category models.Category
+	require.NoError(t, db.Where("category_id = ?", categoryID).Take(&category).Error)
+
+	return category.ParentID
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func listCategories(t *testing.T, app *fiber.App, cookie *http.Cookie) []categoryPayload {
+	return listCategoriesAt(t, app, cookie, "/api/categories")
+}
+
+func listCategoriesAt(t *testing.T, app *fiber.App, cookie *http.Cookie, path string) []categoryPayload {
+	resp := doRequest(t, app, http.MethodGet, path, nil, []*http.Cookie{cookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+
+	var categories []categoryPayload
+	require.NoError(t, json.Unmarshal(parsed.Data, &categories))
+
+	return categories
+}
+
+func categoryNames(categories []categoryPayload) []string {
+	names := make([]string, 0, len(categories))
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+
+	return names
+}
+
+// seedTransactions logs in a fresh user holding one USD income ("Salary") and one UYU expense ("Food").
+func seedTransactions(t *testing.T, app *fiber.App) (*http.Cookie, categoryPayload) {
+	user := createUser(t, app)
+	sessionCookie := login(t, app, user.Email, "secret123")
+	category := createCategory(t, app, sessionCookie)
+	createTransaction(t, app, sessionCookie, category.CategoryID)
+
+	body := map[string]interface{}{
+		"type":     "EXPENSE",
+		"amount":   250,
+		"currency": "UYU",
+		"day":      15,
+		"month":    "FEBRUARY",
+		"year":     2024,
+		"category": map[string]string{"name": "Food"},
+	}
+	resp := doRequest(t, app, http.MethodPost, "/api/transactions", body, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	return sessionCookie, category
+}
+
 type userPayload struct {
-	UserID    string `json:"userId"`
-	Email     string `json:"email"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	UserID           string `json:"userId"`
+	Email            string `json:"email"`
+	FirstName        string `json:"firstName"`
+	LastName         string `json:"lastName"`
+	EmailVerified    bool   `json:"emailVerified"`
+	TwoFactorEnabled bool   `json:"twoFactorEnabled"`
 }
 
 type categoryPayload struct {
@@ -55,9 +1575,326 @@ type categoryPayload struct {
 
 type transactionPayload struct {
 	TransactionID string  `json:"transactionId"`
+	Amount        float64 `json:"amount"`
 	CategoryID    *string `json:"categoryId"`
 }
 
+func TestTwoFactorLogin(t *testing.T) {
+	db := newTestDB(t)
+	mr, redisClient := newTestRedisServer(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	user := createUser(t, app)
+	sessionCookie := login(t, app, user.Email, "secret123")
+	cookies := []*http.Cookie{sessionCookie}
+
+	codeBody := func(code string) map[string]string {
+		return map[string]string{"code": code}
+	}
+
+	resp := doRequest(t, app, http.MethodPost, "/api/auth/2fa/confirm", codeBody("123456"), cookies)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	var enrollment struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/2fa/enroll", nil, cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+	require.NoError(t, json.Unmarshal(parsed.Data, &enrollment))
+	require.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/"))
+	require.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/2fa/confirm", codeBody("abcdef"), cookies)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	firstCode, err := service.TOTPCode(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+
+	var recovery struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/2fa/confirm", codeBody(firstCode), cookies)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	decodeResponse(t, resp.Body, &parsed)
+	require.NoError(t, json.Unmarshal(parsed.Data, &recovery))
+	require.Len(t, recovery.RecoveryCodes, 10)
+
+	var stored models.User
+	require.NoError(t, db.Where("user_id = ?", user.UserID).Take(&stored).Error)
+	require.NotNil(t, stored.TOTPEnabledAt)
+	require.NotContains(t, stored.RecoveryCodes, recovery.RecoveryCodes[0])
+
+	// The password alone only yields a pending session.
+	startLogin := func() *http.Cookie {
+		resp := doRequest(t, app, http.MethodPost, "/api/auth/login", map[string]string{"email": user.Email, "password": "secret123"}, nil)
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		pending := findCookie(resp.Cookies(), "sessionID")
+		require.NotNil(t, pending)
+
+		return pending
+	}
+
+	pending := startLogin()
+	resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{pending})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Codes cannot be replayed.
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/2fa/verify", codeBody(firstCode), []*http.Cookie{pending})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	nextCode, err := service.TOTPCode(enrollment.Secret, time.Now().Add(30*time.Second))
+	require.NoError(t, err)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/2fa/verify", codeBody(nextCode), []*http.Cookie{pending})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	verified := findCookie(resp.Cookies(), "sessionID")
+	require.NotNil(t, verified)
+	require.NotEqual(t, pending.Value, verified.Value)
+
+	var me userPayload
+	getData(t, app, verified, "/api/users", &me)
+	require.True(t, me.TwoFactorEnabled)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{pending})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Recovery codes work once, in any case and without dashes.
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/2fa/verify", codeBody(strings.ToUpper(recovery.RecoveryCodes[0])), []*http.Cookie{startLogin()})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/2fa/verify", codeBody(strings.ReplaceAll(recovery.RecoveryCodes[0], "-", "")), []*http.Cookie{startLogin()})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Logging out everywhere also cancels logins waiting for their second factor, which are not listed.
+	pending = startLogin()
+	var sessions []json.RawMessage
+	getData(t, app, verified, "/api/auth/sessions", &sessions)
+	require.Len(t, sessions, 3)
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/auth/sessions", nil, []*http.Cookie{verified})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/2fa/verify", codeBody(recovery.RecoveryCodes[2]), []*http.Cookie{pending})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodPost, "/api/auth/2fa/verify", codeBody(recovery.RecoveryCodes[2]), []*http.Cookie{startLogin()})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	verified = findCookie(resp.Cookies(), "sessionID")
+	require.NotNil(t, verified)
+
+	// Disabling asks for the password too, and wrong codes lock the account like failed logins.
+	disableBody := func(password, code string) map[string]string {
+		return map[string]string{"password": password, "code": code}
+	}
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/auth/2fa", codeBody(recovery.RecoveryCodes[1]), []*http.Cookie{verified})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/auth/2fa", disableBody("wrong-password", recovery.RecoveryCodes[1]), []*http.Cookie{verified})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	for i := 0; i < 4; i++ {
+		resp = doRequest(t, app, http.MethodDelete, "/api/auth/2fa", disableBody("secret123", "000000"), []*http.Cookie{verified})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/auth/2fa", disableBody("secret123", recovery.RecoveryCodes[1]), []*http.Cookie{verified})
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	mr.FastForward(time.Hour)
+	resp = doRequest(t, app, http.MethodDelete, "/api/auth/2fa", disableBody("secret123", recovery.RecoveryCodes[1]), []*http.Cookie{verified})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	login(t, app, user.Email, "secret123")
+}
+
+func TestOIDCLogin(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+	provider := newTestOIDCProvider(t)
+
+	cfg := testConfig()
+	cfg.AppURL = "http://app.test"
+	cfg.OIDCIssuerURL = provider.server.URL
+	cfg.OIDCClientID = "expenses"
+	cfg.OIDCClientSecret = "client-secret"
+	cfg.OIDCRedirectURL = "http://api.test/api/auth/oidc/callback"
+
+	app := server.New(cfg, db, redisClient).App()
+	user := createUser(t, app)
+	provider.email = user.Email
+
+	// Accounts are linked only once their email is verified.
+	resp := oidcLogin(t, app, provider)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	require.NoError(t, db.Model(&models.User{}).Where("user_id = ?", user.UserID).Update("email_verified_at", time.Now()).Error)
+
+	resp = oidcLogin(t, app, provider)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Equal(t, "http://app.test/", resp.Header.Get("Location"))
+
+	sessionCookie := findCookie(resp.Cookies(), "sessionID")
+	require.NotNil(t, sessionCookie)
+
+	var me userPayload
+	getData(t, app, sessionCookie, "/api/users", &me)
+	require.Equal(t, user.UserID, me.UserID)
+
+	// Once linked, the identity is found by its subject even if the email changes at the provider.
+	provider.email = "renamed@example.com"
+	resp = oidcLogin(t, app, provider)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	var identities []models.UserIdentity
+	require.NoError(t, db.Find(&identities).Error)
+	require.Len(t, identities, 1)
+	require.Equal(t, "renamed@example.com", identities[0].Email)
+
+	// Linked accounts still need a verified email when verification is required, as with password logins.
+	require.NoError(t, db.Model(&models.User{}).Where("user_id = ?", user.UserID).Update("email_verified_at", nil).Error)
+	resp = oidcLogin(t, app, provider)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	cfg.RequireVerifiedEmail = true
+	strict := server.New(cfg, db, redisClient).App()
+	resp = oidcLogin(t, strict, provider)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Unknown identities are not linked.
+	provider.subject = "someone-else"
+	resp = oidcLogin(t, app, provider)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// The callback only completes in the browser that started the login, and only once.
+	resp = doRequest(t, app, http.MethodGet, "/api/auth/oidc/login", nil, nil)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback := provider.authorize(t, resp.Header.Get("Location"))
+
+	resp = doRequest(t, app, http.MethodGet, callback, nil, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	stateCookie := findCookie(resp.Cookies(), "oidcState")
+	require.NotNil(t, stateCookie)
+	require.Empty(t, stateCookie.Value)
+}
+
+func TestUpdateProfile(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+	outbox := mail.NewOutbox()
+
+	app := server.New(testConfig(), db, redisClient, server.WithMailer(outbox)).App()
+	user := createUser(t, app)
+	sessionCookie := login(t, app, user.Email, "secret123")
+	cookies := []*http.Cookie{sessionCookie}
+
+	require.NoError(t, db.Model(&models.User{}).Where("user_id = ?", user.UserID).Update("email_verified_at", time.Now()).Error)
+
+	update := func(body map[string]string) *http.Response {
+		return doRequest(t, app, http.MethodPatch, "/api/users/me", body, cookies)
+	}
+
+	resp := update(map[string]string{"firstName": " Jane "})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+	var updated userPayload
+	require.NoError(t, json.Unmarshal(parsed.Data, &updated))
+	require.Equal(t, "Jane", updated.FirstName)
+	require.Equal(t, "User", updated.LastName)
+	require.True(t, updated.EmailVerified)
+
+	require.Equal(t, http.StatusBadRequest, update(map[string]string{"lastName": ""}).StatusCode)
+	require.Equal(t, http.StatusBadRequest, update(map[string]string{"email": "not-an-email"}).StatusCode)
+
+	other := map[string]string{"email": "other@example.com", "firstName": "Other", "lastName": "User", "password": "secret123"}
+	require.Equal(t, http.StatusCreated, doRequest(t, app, http.MethodPost, "/api/users", other, nil).StatusCode)
+	require.Equal(t, http.StatusConflict, update(map[string]string{"email": "Other@example.com"}).StatusCode)
+
+	// A new email must be verified again.
+	sent := len(outbox.Messages())
+	resp = update(map[string]string{"email": "New@Example.com"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	decodeResponse(t, resp.Body, &parsed)
+	require.NoError(t, json.Unmarshal(parsed.Data, &updated))
+	require.Equal(t, "new@example.com", updated.Email)
+	require.False(t, updated.EmailVerified)
+
+	messages := outbox.Messages()
+	require.Len(t, messages, sent+1)
+	require.Equal(t, "new@example.com", messages[sent].To)
+
+	verify := map[string]string{"token": mailToken(t, messages[sent], "verify-email")}
+	require.Equal(t, http.StatusOK, doRequest(t, app, http.MethodPost, "/api/users/verify", verify, nil).StatusCode)
+
+	login(t, app, "new@example.com", "secret123")
+}
+
+func TestDeleteAccount(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+
+	app := server.New(testConfig(), db, redisClient).App()
+	sessionCookie, _ := seedTransactions(t, app)
+	otherSession := login(t, app, "demo@example.com", "secret123")
+
+	resp := doRequest(t, app, http.MethodPost, "/api/auth/tokens", map[string]string{"name": "script", "scope": "READ"}, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var parsed customResponse
+	decodeResponse(t, resp.Body, &parsed)
+	var token struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(parsed.Data, &token))
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/users/me", map[string]string{"password": "wrong-password"}, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = doBearerRequest(t, app, http.MethodDelete, "/api/users/me", map[string]string{"password": "secret123"}, token.Token)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doRequest(t, app, http.MethodDelete, "/api/users/me", map[string]string{"password": "secret123"}, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, cookie := range []*http.Cookie{sessionCookie, otherSession} {
+		resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{cookie})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	resp = doBearerRequest(t, app, http.MethodGet, "/api/transactions", nil, token.Token)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	for _, model := range []interface{}{&models.User{}, &models.Category{}, &models.Transaction{}, &models.APIToken{}} {
+		var count int64
+		require.NoError(t, db.Unscoped().Model(model).Count(&count).Error)
+		require.Zero(t, count)
+	}
+
+	// The email is free again.
+	user := createUser(t, app)
+	sessionCookie = login(t, app, user.Email, "secret123")
+
+	// Failing to revoke the sessions does not fail a deletion that already happened.
+	require.NoError(t, redisClient.Set(context.Background(), "sessions:"+user.UserID, "corrupted", 0).Err())
+	resp = doRequest(t, app, http.MethodDelete, "/api/users/me", map[string]string{"password": "secret123"}, []*http.Cookie{sessionCookie})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cleared := findCookie(resp.Cookies(), "sessionID")
+	require.NotNil(t, cleared)
+	require.Empty(t, cleared.Value)
+
+	resp = doRequest(t, app, http.MethodGet, "/api/users", nil, []*http.Cookie{sessionCookie})
+	require.NotEqual(t, http.StatusOK, resp.StatusCode)
+}
+
 type customResponse struct {
 	Result bool            `json:"result"`
 	Data   json.RawMessage `json:"data"`
@@ -104,6 +1941,10 @@ func createCategory(t *testing.T, app *fiber.App, cookie *http.Cookie) categoryP
 		"type": "INCOME",
 	}
 
+	return postCategory(t, app, cookie, body)
+}
+
+func postCategory(t *testing.T, app *fiber.App, cookie *http.Cookie, body interface{}) categoryPayload {
 	resp := doRequest(t, app, http.MethodPost, "/api/categories", body, []*http.Cookie{cookie})
 	require.Equal(t, http.StatusCreated, resp.StatusCode)
 
@@ -135,7 +1976,11 @@ func createTransaction(t *testing.T, app *fiber.App, cookie *http.Cookie, catego
 }
 
 func listTransactions(t *testing.T, app *fiber.App, cookie *http.Cookie) []transactionPayload {
-	resp := doRequest(t, app, http.MethodGet, "/api/transactions", nil, []*http.Cookie{cookie})
+	return getTransactions(t, app, cookie, "/api/transactions")
+}
+
+func getTransactions(t *testing.T, app *fiber.App, cookie *http.Cookie, path string) []transactionPayload {
+	resp := doRequest(t, app, http.MethodGet, path, nil, []*http.Cookie{cookie})
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 
 	var parsed customResponse
@@ -148,6 +1993,14 @@ func listTransactions(t *testing.T, app *fiber.App, cookie *http.Cookie) []trans
 }
 
 func doRequest(t *testing.T, app *fiber.App, method, path string, body interface{}, cookies []*http.Cookie) *http.Response {
+	return doRequestWithHeader(t, app, method, path, body, cookies, nil)
+}
+
+func doBearerRequest(t *testing.T, app *fiber.App, method, path string, body interface{}, token string) *http.Response {
+	return doRequestWithHeader(t, app, method, path, body, nil, http.Header{"Authorization": {"Bearer " + token}})
+}
+
+func doRequestWithHeader(t *testing.T, app *fiber.App, method, path string, body interface{}, cookies []*http.Cookie, header http.Header) *http.Response {
 	var reader io.Reader
 	if body != nil {
 		payload, err := json.Marshal(body)
@@ -157,6 +2010,11 @@ func doRequest(t *testing.T, app *fiber.App, method, path string, body interface
 
 	req := httptest.NewRequest(method, path, reader)
 	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
 
 	for _, cookie := range cookies {
 		req.AddCookie(cookie)
@@ -185,19 +2043,26 @@ func decodeResponse(t *testing.T, body io.ReadCloser, out interface{}) {
 }
 
 func newTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{DisableForeignKeyConstraintWhenMigrating: true})
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{DisableForeignKeyConstraintWhenMigrating: true})
 	require.NoError(t, err)
 
 	return db
 }
 
 func newTestRedis(t *testing.T) *redis.Client {
+	_, client := newTestRedisServer(t)
+	return client
+}
+
+// newTestRedisServer also returns the miniredis instance so tests can fast-forward expirations.
+func newTestRedisServer(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
 	mr := miniredis.RunT(t)
 
 	opts, err := redis.ParseURL(fmt.Sprintf("redis://%s", mr.Addr()))
 	require.NoError(t, err)
 
-	return redis.NewClient(opts)
+	return mr, redis.NewClient(opts)
 }
 
 func testConfig() config.Config {
@@ -208,6 +2073,8 @@ func testConfig() config.Config {
 		RedisURL:          "",
 		SessionCookieName: "sessionID",
 		SessionTTL:        24 * time.Hour,
+		SessionMaxAge:     72 * time.Hour,
 		CorsOrigins:       []string{"http://test"},
+		CategoryUndoTTL:   10 * time.Minute,
 	}
 }
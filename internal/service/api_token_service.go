@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// apiTokenPrefix marks the secrets issued by this API so they are easy to spot in scripts and secret scanners.
+const apiTokenPrefix = "nexp_"
+
+// APITokenService manages personal access tokens.
+type APITokenService struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// CreateAPITokenInput describes a new token. Tokens without ExpiresInDays never expire.
+type CreateAPITokenInput struct {
+	Name          string            `json:"name" validate:"required,max=100"`
+	Scope         models.TokenScope `json:"scope" validate:"required,oneof=READ WRITE"`
+	ExpiresInDays *int              `json:"expiresInDays" validate:"omitempty,min=1,max=365"`
+}
+
+func NewAPITokenService(db *gorm.DB) *APITokenService {
+	return &APITokenService{db: db, validator: validator.New()}
+}
+
+// Create issues a token for the user and returns it with its secret, which is not stored and cannot be shown again.
+func (s *APITokenService) Create(ctx context.Context, userID string, input CreateAPITokenInput) (*models.APIToken, string, error) {
+	input.Name = strings.TrimSpace(input.Name)
+	input.Scope = models.TokenScope(strings.ToUpper(string(input.Scope)))
+	if err := s.validator.Struct(input); err != nil {
+		return nil, "", apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
+	}
+
+	random, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret := apiTokenPrefix + random
+
+	token := &models.APIToken{
+		TokenID:   uuid.NewString(),
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    secret[:len(apiTokenPrefix)+6],
+		TokenHash: hashAPIToken(secret),
+		Scope:     input.Scope,
+	}
+
+	if input.ExpiresInDays != nil {
+		expiresAt := time.Now().Add(time.Duration(*input.ExpiresInDays) * 24 * time.Hour)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.WithContext(ctx).Create(token).Error; err != nil {
+		return nil, "", err
+	}
+
+	return token, secret, nil
+}
+
+// List returns the tokens of the user, newest first.
+func (s *APITokenService) List(ctx context.Context, userID string) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke deletes a token of the user.
+func (s *APITokenService) Revoke(ctx context.Context, userID, tokenID string) error {
+	result := s.db.WithContext(ctx).Where("token_id = ? AND user_id = ?", tokenID, userID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperror.New(apperror.AuthTokenNotFound, nil)
+	}
+
+	return nil
+}
+
+// Authenticate returns the token matching the secret, or AuthTokenInvalid when it is unknown or expired. The last
+// use is recorded at most once per refreshInterval.
+func (s *APITokenService) Authenticate(ctx context.Context, secret string) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", hashAPIToken(secret)).Take(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.New(apperror.AuthTokenInvalid, nil)
+		}
+
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && !now.Before(*token.ExpiresAt) {
+		return nil, apperror.New(apperror.AuthTokenInvalid, nil)
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= refreshInterval {
+		if err := s.db.WithContext(ctx).Model(&token).Update("last_used_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &token, nil
+}
+
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 
@@ -15,60 +17,242 @@ import (
 
 // AuthService authenticates users and manages session state in Redis.
 type AuthService struct {
-	users *UserService
-	redis *redis.Client
-	ttl   time.Duration
+	users           *UserService
+	twoFactor       *TwoFactorService
+	redis           *redis.Client
+	sessions        SessionOptions
+	requireVerified bool
 }
 
-// NewAuthService builds a new AuthService instance.
-func NewAuthService(users *UserService, redis *redis.Client, ttl time.Duration) *AuthService {
-	return &AuthService{users: users, redis: redis, ttl: ttl}
+// SessionOptions controls how long sessions live. IdleTTL is the inactivity timeout, extended as the session is used;
+// MaxAge caps the lifetime from login regardless of activity and defaults to IdleTTL when zero.
+type SessionOptions struct {
+	IdleTTL time.Duration
+	MaxAge  time.Duration
 }
 
-// Login validates credentials and stores the user identifier in Redis using the provided session key.
-func (s *AuthService) Login(ctx context.Context, email, password, sessionID string) (*models.User, string, error) {
+// NewAuthService builds a new AuthService instance. When requireVerified is set, users cannot log in until they
+// verify their email.
+func NewAuthService(users *UserService, twoFactor *TwoFactorService, redis *redis.Client, sessions SessionOptions, requireVerified bool) *AuthService {
+	if sessions.MaxAge <= 0 {
+		sessions.MaxAge = sessions.IdleTTL
+	}
+
+	return &AuthService{users: users, twoFactor: twoFactor, redis: redis, sessions: sessions, requireVerified: requireVerified}
+}
+
+// Login validates credentials and stores the user identifier in Redis under a freshly minted session key. The session
+// the client presented before logging in (if any) is never promoted and is invalidated, preventing session fixation.
+// Unknown emails and wrong passwords fail alike, and repeated failures lock the account or client IP for a while.
+// For users with two-factor authentication the returned session is pending: it authenticates nothing until
+// CompleteLogin receives a valid code.
+func (s *AuthService) Login(ctx context.Context, email, password, previousSessionID string, info SessionInfo) (*models.User, string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := s.checkLoginLock(ctx, email, info.IP); err != nil {
+		return nil, "", err
+	}
+
 	user, err := s.users.FindByEmail(ctx, email)
 	if err != nil {
-		return nil, "", err
+		if !apperror.Is(err, apperror.UserNotFound) {
+			return nil, "", err
+		}
+
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, "", s.loginFailed(ctx, email, info.IP)
 	}
 
 	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
-		return nil, "", apperror.New(apperror.AuthBadAuth, nil)
+		return nil, "", s.loginFailed(ctx, email, info.IP)
 	}
 
-	sessionKey := sessionID
-	if sessionKey == "" {
-		sessionKey = uuid.NewString()
+	// Failures are kept until the second factor is passed too, so each password login does not grant new guesses.
+	if user.TOTPEnabledAt == nil {
+		if err := s.clearLoginFailures(ctx, email); err != nil {
+			return nil, "", err
+		}
 	}
 
-	redisKey := s.userSessionKey(sessionKey)
-	if err := s.redis.Set(ctx, redisKey, user.UserID, s.ttl).Err(); err != nil {
+	sessionKey, err := s.StartSession(ctx, user, previousSessionID, info)
+	if err != nil {
 		return nil, "", err
 	}
 
 	return user, sessionKey, nil
 }
 
-// Logout removes the redis entry connected with the incoming session.
+// StartSession opens a session for a user authenticated by the caller, replacing the previous session. Users with
+// two-factor authentication get a pending session, as in Login. Every login path goes through it, so it also rejects
+// unverified emails when verification is required.
+func (s *AuthService) StartSession(ctx context.Context, user *models.User, previousSessionID string, info SessionInfo) (string, error) {
+	if s.requireVerified && user.EmailVerifiedAt == nil {
+		return "", apperror.New(apperror.AuthEmailUnverified, nil)
+	}
+
+	sessionKey, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		err = s.storePendingSession(ctx, user.UserID, sessionKey, info)
+	} else {
+		err = s.storeSession(ctx, user.UserID, sessionKey, info)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.Logout(ctx, previousSessionID); err != nil {
+		return "", err
+	}
+
+	return sessionKey, nil
+}
+
+// CompleteLogin checks the second factor of a pending session and replaces it with a regular one. Wrong codes count
+// as failed logins of the account.
+func (s *AuthService) CompleteLogin(ctx context.Context, pendingSessionID, code string) (*models.User, string, error) {
+	if pendingSessionID == "" {
+		return nil, "", apperror.New(apperror.AuthNeedLogin, nil)
+	}
+
+	pending, err := s.redis.HGetAll(ctx, s.pendingSessionKey(pendingSessionID)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(pending) == 0 {
+		return nil, "", apperror.New(apperror.AuthNeedLogin, nil)
+	}
+
+	user, err := s.users.GetByID(ctx, pending["userId"])
+	if err != nil {
+		return nil, "", err
+	}
+
+	info := SessionInfo{IP: pending["ip"], UserAgent: pending["userAgent"]}
+	if err := s.checkLoginLock(ctx, user.Email, info.IP); err != nil {
+		return nil, "", err
+	}
+
+	if err := s.twoFactor.Verify(ctx, user, code); err != nil {
+		if !apperror.Is(err, apperror.AuthTwoFactorInvalid) {
+			return nil, "", err
+		}
+
+		if err := s.recordLoginFailure(ctx, user.Email, info.IP); err != nil {
+			return nil, "", err
+		}
+
+		return nil, "", apperror.New(apperror.AuthTwoFactorInvalid, nil)
+	}
+
+	if err := s.clearLoginFailures(ctx, user.Email); err != nil {
+		return nil, "", err
+	}
+
+	// The pending key is consumed only once so two requests cannot both turn it into a session.
+	deleted, err := s.redis.Del(ctx, s.pendingSessionKey(pendingSessionID)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if deleted == 0 {
+		return nil, "", apperror.New(apperror.AuthNeedLogin, nil)
+	}
+
+	if err := s.redis.SRem(ctx, s.userSessionsKey(user.UserID), pendingSessionID).Err(); err != nil {
+		return nil, "", err
+	}
+
+	sessionKey, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.storeSession(ctx, user.UserID, sessionKey, info); err != nil {
+		return nil, "", err
+	}
+
+	return user, sessionKey, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking the password and a current code. Wrong answers
+// count as failed logins of the account so a stolen session cannot be used to guess codes.
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID, password, code, ip string) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkLoginLock(ctx, user.Email, ip); err != nil {
+		return err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return s.loginFailed(ctx, user.Email, ip)
+	}
+
+	if err := s.twoFactor.Disable(ctx, user, code); err != nil {
+		if !apperror.Is(err, apperror.AuthTwoFactorInvalid) {
+			return err
+		}
+
+		if err := s.recordLoginFailure(ctx, user.Email, ip); err != nil {
+			return err
+		}
+
+		return apperror.New(apperror.AuthTwoFactorInvalid, nil)
+	}
+
+	return s.clearLoginFailures(ctx, user.Email)
+}
+
+// Logout removes the redis entries connected with the incoming session.
 func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
 	if sessionID == "" {
 		return nil
 	}
 
-	if err := s.redis.Del(ctx, s.userSessionKey(sessionID)).Err(); err != nil && err != redis.Nil {
+	userID, err := s.redis.Get(ctx, s.userSessionKey(sessionID)).Result()
+	if err == redis.Nil {
+		userID, err = s.redis.HGet(ctx, s.pendingSessionKey(sessionID), "userId").Result()
+	}
+	if err != nil && err != redis.Nil {
 		return err
 	}
 
-	return nil
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.userSessionKey(sessionID), s.sessionKey(sessionID), s.pendingSessionKey(sessionID))
+		if userID != "" {
+			pipe.SRem(ctx, s.userSessionsKey(userID), sessionID)
+		}
+		return nil
+	})
+
+	return err
 }
 
-// ResolveSession returns the user identifier associated with the session if it exists.
+// ResolveSession returns the user identifier associated with the session if it exists. Activity slides the idle
+// timeout and updates lastSeen, at most once per refreshInterval, never past the session's maximum age.
 func (s *AuthService) ResolveSession(ctx context.Context, sessionID string) (string, error) {
 	if sessionID == "" {
 		return "", nil
 	}
 
-	userID, err := s.redis.Get(ctx, s.userSessionKey(sessionID)).Result()
+	var userCmd *redis.StringCmd
+	var idleCmd, remainingCmd *redis.DurationCmd
+	if _, err := s.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		userCmd = pipe.Get(ctx, s.userSessionKey(sessionID))
+		idleCmd = pipe.PTTL(ctx, s.userSessionKey(sessionID))
+		remainingCmd = pipe.PTTL(ctx, s.sessionKey(sessionID))
+		return nil
+	}); err != nil && err != redis.Nil {
+		return "", err
+	}
+
+	userID, err := userCmd.Result()
 	if err != nil {
 		if err == redis.Nil {
 			return "", nil
@@ -77,9 +261,29 @@ func (s *AuthService) ResolveSession(ctx context.Context, sessionID string) (str
 		return "", err
 	}
 
+	// The metadata hash expires at the maximum age; sessions without it keep their fixed expiration.
+	remaining := remainingCmd.Val()
+	if remaining <= 0 || s.sessions.IdleTTL-idleCmd.Val() < refreshInterval {
+		return userID, nil
+	}
+
+	if err := s.touchSession(ctx, sessionID, min(s.sessions.IdleTTL, remaining)); err != nil {
+		return "", err
+	}
+
 	return userID, nil
 }
 
+// randomToken returns 256 random bits, URL-safe encoded, for session ids and other secrets.
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
 func (s *AuthService) userSessionKey(sessionID string) string {
 	return fmt.Sprintf("user:%s", sessionID)
 }
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+const (
+	// refreshInterval throttles the activity updates so active sessions do not write to Redis on every request.
+	refreshInterval = time.Minute
+	// pendingSessionTTL is how long a login waits for its second factor.
+	pendingSessionTTL = 5 * time.Minute
+)
+
+// SessionInfo describes the client that opened a session.
+type SessionInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// Session is the metadata of an active session. ID is derived from the session key so it can be exposed safely.
+type Session struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Current   bool      `json:"current"`
+}
+
+// PublicSessionID returns the identifier under which a session is listed and revoked.
+func PublicSessionID(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Sessions lists the active sessions of the user, most recently used first, flagging the one making the request.
+func (s *AuthService) Sessions(ctx context.Context, userID, currentSessionID string) ([]Session, error) {
+	sessionIDs, err := s.redis.SMembers(ctx, s.userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		fields, err := s.redis.HGetAll(ctx, s.sessionKey(sessionID)).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// Expired sessions linger in the index until they are listed. Logins waiting for their second factor are
+		// indexed so LogoutEverywhere reaches them, but they are not listed.
+		if len(fields) == 0 {
+			pending, err := s.redis.Exists(ctx, s.pendingSessionKey(sessionID)).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			if pending == 0 {
+				if err := s.redis.SRem(ctx, s.userSessionsKey(userID), sessionID).Err(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		createdAt, _ := time.Parse(time.RFC3339Nano, fields["createdAt"])
+		lastSeen, _ := time.Parse(time.RFC3339Nano, fields["lastSeen"])
+
+		sessions = append(sessions, Session{
+			ID:        PublicSessionID(sessionID),
+			CreatedAt: createdAt,
+			LastSeen:  lastSeen,
+			IP:        fields["ip"],
+			UserAgent: fields["userAgent"],
+			Current:   sessionID == currentSessionID,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession ends the session of the user listed under the public identifier.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, publicID string) error {
+	sessionIDs, err := s.redis.SMembers(ctx, s.userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if PublicSessionID(sessionID) == publicID {
+			return s.Logout(ctx, sessionID)
+		}
+	}
+
+	return apperror.New(apperror.AuthSessionNotFound, nil)
+}
+
+// LogoutEverywhere ends every session of the user except exceptSessionID (pass "" to end them all), including logins
+// waiting for their second factor. Sessions opened before the index existed are not reached and expire on their own.
+func (s *AuthService) LogoutEverywhere(ctx context.Context, userID, exceptSessionID string) error {
+	sessionIDs, err := s.redis.SMembers(ctx, s.userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if sessionID == exceptSessionID {
+			continue
+		}
+
+		if err := s.Logout(ctx, sessionID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// storeSession writes the session key, its metadata and its entry in the user's session index. The metadata lives
+// for the maximum age while the session key expires after the idle timeout unless the session is used.
+func (s *AuthService) storeSession(ctx context.Context, userID, sessionID string, info SessionInfo) error {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.userSessionKey(sessionID), userID, min(s.sessions.IdleTTL, s.sessions.MaxAge))
+		pipe.HSet(ctx, s.sessionKey(sessionID), map[string]interface{}{
+			"userId":    userID,
+			"createdAt": now,
+			"lastSeen":  now,
+			"ip":        info.IP,
+			"userAgent": info.UserAgent,
+		})
+		pipe.Expire(ctx, s.sessionKey(sessionID), s.sessions.MaxAge)
+		pipe.SAdd(ctx, s.userSessionsKey(userID), sessionID)
+		pipe.Expire(ctx, s.userSessionsKey(userID), max(s.sessions.IdleTTL, s.sessions.MaxAge))
+		return nil
+	})
+
+	return err
+}
+
+// storePendingSession remembers a login waiting for its second factor. It is not a session yet: ResolveSession
+// ignores it. It is still indexed so LogoutEverywhere can cancel it.
+func (s *AuthService) storePendingSession(ctx context.Context, userID, sessionID string, info SessionInfo) error {
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.pendingSessionKey(sessionID), map[string]interface{}{
+			"userId":    userID,
+			"ip":        info.IP,
+			"userAgent": info.UserAgent,
+		})
+		pipe.Expire(ctx, s.pendingSessionKey(sessionID), pendingSessionTTL)
+		pipe.SAdd(ctx, s.userSessionsKey(userID), sessionID)
+		pipe.Expire(ctx, s.userSessionsKey(userID), max(s.sessions.IdleTTL, s.sessions.MaxAge))
+		return nil
+	})
+
+	return err
+}
+
+// touchSession records activity on a session, extending its key by ttl.
+func (s *AuthService) touchSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.PExpire(ctx, s.userSessionKey(sessionID), ttl)
+		pipe.HSet(ctx, s.sessionKey(sessionID), "lastSeen", time.Now().UTC().Format(time.RFC3339Nano))
+		return nil
+	})
+
+	return err
+}
+
+func (s *AuthService) sessionKey(sessionID string) string {
+	return fmt.Sprintf("session:%s", sessionID)
+}
+
+func (s *AuthService) pendingSessionKey(sessionID string) string {
+	return fmt.Sprintf("session-pending:%s", sessionID)
+}
+
+func (s *AuthService) userSessionsKey(userID string) string {
+	return fmt.Sprintf("sessions:%s", userID)
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+)
+
+// categoryNameIndex backs the "one active category per user, type and name" rule. It deliberately ignores parent_id:
+// inline categories are matched by name alone, which needs names to be unique across the whole tree.
+const categoryNameIndex = "idx_categories_user_type_name"
+
+var accentFolder = strings.NewReplacer(
+	"á", "a", "à", "a", "ä", "a", "â", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ë", "e", "ê", "e",
+	"í", "i", "ì", "i", "ï", "i", "î", "i",
+	"ó", "o", "ò", "o", "ö", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ù", "u", "ü", "u", "û", "u",
+	"ñ", "n", "ç", "c",
+)
+
+// normalizeCategoryName lower-cases the name, strips accents and collapses whitespace so "Almacén " matches "almacen".
+func normalizeCategoryName(name string) string {
+	return strings.Join(strings.Fields(accentFolder.Replace(strings.ToLower(name))), " ")
+}
+
+// findByNormalizedName returns the active category of the user with the same type and normalized name, if any.
+func findByNormalizedName(db *gorm.DB, userID string, categoryType models.TransactionType, normalizedName string) (*models.Category, error) {
+	var category models.Category
+	if err := db.Where("user_id = ? AND type = ? AND normalized_name = ?", userID, categoryType, normalizedName).
+		Order("created_at ASC").
+		Take(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// EnsureNameIndex backfills normalized names and creates the unique index behind name deduplication.
+// It fails while duplicates exist; MergeDuplicates cleans them up.
+func (s *CategoryService) EnsureNameIndex(ctx context.Context) error {
+	if err := s.backfillNormalizedNames(ctx); err != nil {
+		return err
+	}
+
+	statement := fmt.Sprintf(
+		"CREATE UNIQUE INDEX IF NOT EXISTS %s ON categories (user_id, type, normalized_name) WHERE deleted_at IS NULL",
+		categoryNameIndex,
+	)
+
+	if err := s.db.WithContext(ctx).Exec(statement).Error; err != nil {
+		return fmt.Errorf("create %s: %w", categoryNameIndex, err)
+	}
+
+	return nil
+}
+
+// MergeDuplicates merges every group of active categories sharing user, type and normalized name into the oldest one.
+// It returns how many categories were merged away.
+func (s *CategoryService) MergeDuplicates(ctx context.Context) (int, error) {
+	if err := s.backfillNormalizedNames(ctx); err != nil {
+		return 0, err
+	}
+
+	var categories []models.Category
+	if err := s.db.WithContext(ctx).Order("created_at ASC").Find(&categories).Error; err != nil {
+		return 0, err
+	}
+
+	type groupKey struct {
+		userID string
+		kind   models.TransactionType
+		name   string
+	}
+
+	keepers := map[groupKey]string{}
+	duplicates := map[string][]string{}
+	order := make([]groupKey, 0)
+	for _, category := range categories {
+		key := groupKey{userID: category.UserID, kind: category.Type, name: category.NormalizedName}
+		keeper, ok := keepers[key]
+		if !ok {
+			keepers[key] = category.CategoryID
+			order = append(order, key)
+			continue
+		}
+
+		duplicates[keeper] = append(duplicates[keeper], category.CategoryID)
+	}
+
+	merged := 0
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, key := range order {
+			keeper := keepers[key]
+			if len(duplicates[keeper]) == 0 {
+				continue
+			}
+
+			if err := mergeInto(tx, key.userID, keeper, duplicates[keeper]); err != nil {
+				return err
+			}
+			merged += len(duplicates[keeper])
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return merged, nil
+}
+
+// backfillNormalizedNames fills the normalized name of rows created before the column existed, trashed ones included.
+func (s *CategoryService) backfillNormalizedNames(ctx context.Context) error {
+	var categories []models.Category
+	if err := s.db.WithContext(ctx).Unscoped().
+		Where("normalized_name IS NULL OR normalized_name = ''").
+		Find(&categories).Error; err != nil {
+		return err
+	}
+
+	for _, category := range categories {
+		if err := s.db.WithContext(ctx).Unscoped().Model(&models.Category{}).
+			Where("category_id = ?", category.CategoryID).
+			Update("normalized_name", normalizeCategoryName(category.Name)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"slices"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
@@ -14,15 +16,36 @@ import (
 
 // CategoryService contains the logic necessary to manage categories.
 type CategoryService struct {
-	db        *gorm.DB
-	validator *validator.Validate
+	db         *gorm.DB
+	validator  *validator.Validate
+	undoWindow time.Duration
+	templates  CategoryTemplates
 }
 
 // CreateCategoryInput contains the payload required to create a category.
 type CreateCategoryInput struct {
-	Type models.TransactionType `json:"type" validate:"required,oneof=INCOME EXPENSE SAVING INSTALLMENTS"`
-	Name string                 `json:"name" validate:"required"`
-	Note string                 `json:"note"`
+	Type     models.TransactionType `json:"type" validate:"required,oneof=INCOME EXPENSE SAVING INSTALLMENTS"`
+	Name     string                 `json:"name" validate:"required"`
+	Note     string                 `json:"note"`
+	ParentID *string                `json:"parentId"`
+	Color    string                 `json:"color" validate:"omitempty,hexcolor"`
+	Icon     string                 `json:"icon" validate:"omitempty,max=64"`
+}
+
+// UpdateCategoryInput contains the editable attributes of a category. Omitted fields are left untouched and
+// a null parentId moves the category back to the top level.
+type UpdateCategoryInput struct {
+	Name     *string        `json:"name" validate:"omitempty,min=1"`
+	Note     *string        `json:"note"`
+	ParentID OptionalString `json:"parentId"`
+	Color    *string        `json:"color" validate:"omitempty,hexcolor"`
+	Icon     *string        `json:"icon" validate:"omitempty,max=64"`
+	Archived *bool          `json:"archived"`
+}
+
+// ReorderCategoriesInput lists category IDs in the order they should be displayed.
+type ReorderCategoriesInput struct {
+	CategoryIDs []string `json:"categoryIds" validate:"required,min=1,dive,required"`
 }
 
 // UpdateCategoryPayload is used when the transaction service needs to create a category on the fly.
@@ -32,74 +55,499 @@ type UpdateCategoryPayload struct {
 	Note string                 `json:"note"`
 }
 
-func NewCategoryService(db *gorm.DB) *CategoryService {
-	return &CategoryService{db: db, validator: validator.New()}
+func NewCategoryService(db *gorm.DB, undoWindow time.Duration, templates CategoryTemplates) *CategoryService {
+	return &CategoryService{db: db, validator: validator.New(), undoWindow: undoWindow, templates: templates}
 }
 
+// Create persists a new category, refusing names already used by another category of the same type.
 func (s *CategoryService) Create(ctx context.Context, userID string, input CreateCategoryInput) (*models.Category, error) {
+	existing, err := findByNormalizedName(s.db.WithContext(ctx), userID, input.Type, normalizeCategoryName(input.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return nil, apperror.New(apperror.CategoryExists, nil)
+	}
+
 	return s.createWithDB(ctx, nil, userID, input)
 }
 
+// Update edits the name, note, parent or appearance of a category, and archives or unarchives it.
+func (s *CategoryService) Update(ctx context.Context, userID, categoryID string, input UpdateCategoryInput) (*models.Category, error) {
+	if err := s.validator.Struct(input); err != nil {
+		return nil, apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
+	}
+
+	var category *models.Category
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		category, err = s.getByIDWithDB(ctx, tx, userID, categoryID)
+		if err != nil {
+			return err
+		}
+
+		if input.Name != nil {
+			normalized := normalizeCategoryName(*input.Name)
+			existing, err := findByNormalizedName(tx, userID, category.Type, normalized)
+			if err != nil {
+				return err
+			}
+
+			if existing != nil && existing.CategoryID != category.CategoryID {
+				return apperror.New(apperror.CategoryExists, nil)
+			}
+
+			category.Name = *input.Name
+			category.NormalizedName = normalized
+		}
+
+		if input.Note != nil {
+			category.Note = *input.Note
+		}
+
+		if input.Color != nil {
+			category.Color = *input.Color
+		}
+
+		if input.Icon != nil {
+			category.Icon = *input.Icon
+		}
+
+		if input.Archived != nil {
+			category.Archived = *input.Archived
+		}
+
+		if input.ParentID.Set {
+			if input.ParentID.Value != nil {
+				if err := validateParent(tx, userID, category.CategoryID, *input.ParentID.Value, category.Type); err != nil {
+					return err
+				}
+			}
+
+			category.ParentID = input.ParentID.Value
+		}
+
+		return tx.Model(category).
+			Select("name", "normalized_name", "note", "parent_id", "color", "icon", "archived").
+			Updates(category).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
 func (s *CategoryService) GetByID(ctx context.Context, userID, categoryID string) (*models.Category, error) {
 	return s.getByIDWithDB(ctx, nil, userID, categoryID)
 }
 
-func (s *CategoryService) List(ctx context.Context, userID string) ([]models.Category, error) {
+// List returns the categories of the user in display order. Archived categories are only included when requested.
+func (s *CategoryService) List(ctx context.Context, userID string, includeArchived bool) ([]models.Category, error) {
+	query := s.db.WithContext(ctx).Where("user_id = ?", userID)
+	if !includeArchived {
+		query = query.Where("archived = ?", false)
+	}
+
 	var categories []models.Category
-	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&categories).Error; err != nil {
+	if err := query.Order("position ASC").Order("created_at DESC").Find(&categories).Error; err != nil {
 		return nil, err
 	}
 
 	return categories, nil
 }
 
-func (s *CategoryService) Delete(ctx context.Context, userID, categoryID string, deleteTransactions bool) error {
+// Reorder moves the provided categories, in that order, to the top of the list. The remaining categories keep their
+// relative order after them.
+func (s *CategoryService) Reorder(ctx context.Context, userID string, input ReorderCategoriesInput) error {
+	if err := s.validator.Struct(input); err != nil {
+		return apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current []string
+		if err := tx.Model(&models.Category{}).
+			Where("user_id = ?", userID).
+			Order("position ASC").Order("created_at DESC").
+			Pluck("category_id", &current).Error; err != nil {
+			return err
+		}
+
+		owned := make(map[string]bool, len(current))
+		for _, categoryID := range current {
+			owned[categoryID] = true
+		}
+
+		ordered := make([]string, 0, len(current))
+		placed := make(map[string]bool, len(input.CategoryIDs))
+		for _, categoryID := range input.CategoryIDs {
+			if !owned[categoryID] {
+				return apperror.New(apperror.CategoryNotFound, nil)
+			}
+
+			if !placed[categoryID] {
+				placed[categoryID] = true
+				ordered = append(ordered, categoryID)
+			}
+		}
+
+		for _, categoryID := range current {
+			if !placed[categoryID] {
+				ordered = append(ordered, categoryID)
+			}
+		}
+
+		for position, categoryID := range ordered {
+			if err := tx.Model(&models.Category{}).
+				Where("category_id = ? AND user_id = ?", categoryID, userID).
+				Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Delete trashes the category and either trashes or unlinks its transactions, recording what was done so it can be undone.
+// Subcategories move up to the parent of the deleted category.
+func (s *CategoryService) Delete(ctx context.Context, userID, categoryID string, deleteTransactions bool) (*models.CategoryDeletion, error) {
 	tx := s.db.WithContext(ctx).Begin()
 
 	var category models.Category
 	if err := tx.Where("category_id = ? AND user_id = ?", categoryID, userID).Take(&category).Error; err != nil {
 		tx.Rollback()
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return apperror.New(apperror.CategoryNotFound, nil)
+			return nil, apperror.New(apperror.CategoryNotFound, nil)
 		}
 
-		return err
+		return nil, err
 	}
 
-	var txCount int64
-	if err := tx.Model(&models.Transaction{}).Where("category_id = ? AND user_id = ?", categoryID, userID).Count(&txCount).Error; err != nil {
+	var transactionIDs []string
+	if err := tx.Model(&models.Transaction{}).Where("category_id = ? AND user_id = ?", categoryID, userID).Pluck("transaction_id", &transactionIDs).Error; err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
 	}
 
-	if txCount > 0 && !deleteTransactions {
+	if len(transactionIDs) > 0 && !deleteTransactions {
 		tx.Rollback()
-		return apperror.New(apperror.CategoryHasTransactions, nil)
+		return nil, apperror.New(apperror.CategoryHasTransactions, nil)
+	}
+
+	var childIDs []string
+	if err := tx.Model(&models.Category{}).Where("parent_id = ? AND user_id = ?", categoryID, userID).Pluck("category_id", &childIDs).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	deletion := &models.CategoryDeletion{
+		DeletionID:     uuid.NewString(),
+		CategoryID:     categoryID,
+		UserID:         userID,
+		Mode:           models.CategoryDeletionUnlinkTransactions,
+		TransactionIDs: transactionIDs,
+		ChildIDs:       childIDs,
 	}
 
 	if deleteTransactions {
+		deletion.Mode = models.CategoryDeletionDeleteTransactions
 		if err := tx.Where("category_id = ? AND user_id = ?", categoryID, userID).Delete(&models.Transaction{}).Error; err != nil {
 			tx.Rollback()
-			return err
+			return nil, err
 		}
 	} else {
 		if err := tx.Model(&models.Transaction{}).
 			Where("category_id = ? AND user_id = ?", categoryID, userID).
 			Update("category_id", nil).Error; err != nil {
 			tx.Rollback()
-			return err
+			return nil, err
 		}
 	}
 
+	if err := tx.Model(&models.Category{}).
+		Where("parent_id = ? AND user_id = ?", categoryID, userID).
+		Update("parent_id", category.ParentID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	if err := tx.Delete(&category).Error; err != nil {
 		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Create(deletion).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+// UndoWindow is how long after a deletion UndoDelete is still accepted.
+func (s *CategoryService) UndoWindow() time.Duration {
+	return s.undoWindow
+}
+
+// UndoDelete reverses the latest deletion of the category if it happened within the undo window.
+func (s *CategoryService) UndoDelete(ctx context.Context, userID, categoryID string) (*models.Category, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var deletion models.CategoryDeletion
+		if err := tx.Where("category_id = ? AND user_id = ? AND created_at >= ?", categoryID, userID, time.Now().Add(-s.undoWindow)).
+			Order("created_at DESC").
+			Take(&deletion).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperror.New(apperror.CategoryUndoUnavailable, nil)
+			}
+
+			return err
+		}
+
+		restored, err := reverseCategoryDeletion(tx, userID, categoryID, &deletion, true)
+		if err != nil {
+			return err
+		}
+
+		if !restored {
+			return apperror.New(apperror.CategoryUndoUnavailable, nil)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(ctx, userID, categoryID)
+}
+
+// latestCategoryDeletion returns the most recent deletion record of the category, or nil when there is none.
+func latestCategoryDeletion(tx *gorm.DB, userID, categoryID string) (*models.CategoryDeletion, error) {
+	var deletion models.CategoryDeletion
+	err := tx.Where("category_id = ? AND user_id = ?", categoryID, userID).Order("created_at DESC").Take(&deletion).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &deletion, nil
+}
+
+// reverseCategoryDeletion brings a trashed category back and re-links the transactions and subcategories left behind
+// by deletion, which may be nil when no record is left. Transactions trashed along with the category are only restored
+// when restoreTransactions is set; until then the record is kept, so a later undo can still bring them back even if
+// the category itself was already restored from the trash. Only that record is consumed. It reports false when there
+// was nothing to reverse.
+func reverseCategoryDeletion(tx *gorm.DB, userID, categoryID string, deletion *models.CategoryDeletion, restoreTransactions bool) (bool, error) {
+	var category models.Category
+	if err := tx.Unscoped().Where("category_id = ? AND user_id = ?", categoryID, userID).Take(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	trashed := category.DeletedAt.Valid
+	if !trashed && !restoreTransactions {
+		return false, nil
+	}
+
+	if trashed {
+		conflict, err := findByNormalizedName(tx, userID, category.Type, normalizeCategoryName(category.Name))
+		if err != nil {
+			return false, err
+		}
+
+		if conflict != nil {
+			return false, apperror.New(apperror.CategoryExists, nil)
+		}
+
+		result := tx.Unscoped().Model(&models.Category{}).
+			Where("category_id = ? AND user_id = ? AND deleted_at IS NOT NULL", categoryID, userID).
+			Update("deleted_at", nil)
+		if result.Error != nil {
+			return false, result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return false, nil
+		}
+	}
+
+	if deletion == nil {
+		return trashed, nil
+	}
+
+	// Subcategories come back unless they were moved elsewhere since the deletion.
+	if trashed && len(deletion.ChildIDs) > 0 {
+		children := tx.Model(&models.Category{}).Where("category_id IN ? AND user_id = ?", []string(deletion.ChildIDs), userID)
+		if category.ParentID != nil {
+			children = children.Where("parent_id = ?", *category.ParentID)
+		} else {
+			children = children.Where("parent_id IS NULL")
+		}
+
+		if err := children.Update("parent_id", categoryID).Error; err != nil {
+			return false, err
+		}
+	}
+
+	if len(deletion.TransactionIDs) > 0 {
+		switch {
+		case deletion.Mode == models.CategoryDeletionUnlinkTransactions:
+			if err := tx.Model(&models.Transaction{}).
+				Where("transaction_id IN ? AND user_id = ? AND category_id IS NULL", []string(deletion.TransactionIDs), userID).
+				Update("category_id", categoryID).Error; err != nil {
+				return false, err
+			}
+		case restoreTransactions:
+			if err := tx.Unscoped().Model(&models.Transaction{}).
+				Where("transaction_id IN ? AND user_id = ? AND deleted_at IS NOT NULL", []string(deletion.TransactionIDs), userID).
+				Update("deleted_at", nil).Error; err != nil {
+				return false, err
+			}
+		default:
+			return true, nil
+		}
+	}
+
+	if err := tx.Where("deletion_id = ?", deletion.DeletionID).Delete(&models.CategoryDeletion{}).Error; err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// MergeCategoriesInput lists the categories whose transactions move into the target category.
+type MergeCategoriesInput struct {
+	SourceIDs []string `json:"sourceIds" validate:"required,min=1,dive,required"`
+}
+
+// Merge moves every transaction of the source categories into the target and deletes the sources in one DB transaction.
+func (s *CategoryService) Merge(ctx context.Context, userID, targetID string, input MergeCategoriesInput) (*models.Category, error) {
+	if err := s.validator.Struct(input); err != nil {
+		return nil, apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
+	}
+
+	sourceIDs := make([]string, 0, len(input.SourceIDs))
+	seen := map[string]struct{}{}
+	for _, sourceID := range input.SourceIDs {
+		if sourceID == targetID {
+			return nil, apperror.New(apperror.ServerParamsMissing, "A category cannot be merged into itself")
+		}
+
+		if _, ok := seen[sourceID]; !ok {
+			seen[sourceID] = struct{}{}
+			sourceIDs = append(sourceIDs, sourceID)
+		}
+	}
+
+	var target *models.Category
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		target, err = s.getByIDWithDB(ctx, tx, userID, targetID)
+		if err != nil {
+			return err
+		}
+
+		var sources []models.Category
+		if err := tx.Where("category_id IN ? AND user_id = ?", sourceIDs, userID).Find(&sources).Error; err != nil {
+			return err
+		}
+
+		if len(sources) != len(sourceIDs) {
+			return apperror.New(apperror.CategoryNotFound, nil)
+		}
+
+		for _, source := range sources {
+			if source.Type != target.Type {
+				return apperror.New(apperror.CategoryMergeTypeMismatch, nil)
+			}
+		}
+
+		return mergeInto(tx, userID, target.CategoryID, sourceIDs)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// mergeInto re-points the transactions and subcategories of the source categories to the target and deletes the sources.
+func mergeInto(tx *gorm.DB, userID, targetID string, sourceIDs []string) error {
+	if err := tx.Model(&models.Transaction{}).
+		Where("category_id IN ? AND user_id = ?", sourceIDs, userID).
+		Update("category_id", targetID).Error; err != nil {
+		return err
+	}
+
+	// The target may descend from a source, directly or not. It takes the place of the highest such source, otherwise
+	// re-parenting the sources' children below would close a cycle through the target.
+	parentID, err := mergedTargetParent(tx, userID, targetID, sourceIDs)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Category{}).
+		Where("category_id = ? AND user_id = ?", targetID, userID).
+		Update("parent_id", parentID).Error; err != nil {
 		return err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Model(&models.Category{}).
+		Where("parent_id IN ? AND user_id = ?", sourceIDs, userID).
+		Update("parent_id", targetID).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("category_id IN ? AND user_id = ?", sourceIDs, userID).Delete(&models.Category{}).Error
 }
 
-// EnsureAndCreate validates or creates a category while creating a transaction.
+// mergedTargetParent walks up from the target and returns the parent of the highest ancestor among the sources, or the
+// target's own parent when it does not descend from any source.
+func mergedTargetParent(tx *gorm.DB, userID, targetID string, sourceIDs []string) (*string, error) {
+	var target models.Category
+	if err := tx.Where("category_id = ? AND user_id = ?", targetID, userID).Take(&target).Error; err != nil {
+		return nil, err
+	}
+
+	parentID := target.ParentID
+	current := target.ParentID
+	for depth := 0; current != nil && depth < maxCategoryDepth; depth++ {
+		var ancestor models.Category
+		if err := tx.Where("category_id = ? AND user_id = ?", *current, userID).Take(&ancestor).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+
+			return nil, err
+		}
+
+		if slices.Contains(sourceIDs, ancestor.CategoryID) {
+			parentID = ancestor.ParentID
+		}
+
+		current = ancestor.ParentID
+	}
+
+	return parentID, nil
+}
+
+// EnsureAndCreate validates or creates a category while creating a transaction. Inline categories reuse an existing
+// category of the same type whose name only differs in case, accents or spacing.
 func (s *CategoryService) EnsureAndCreate(ctx context.Context, db *gorm.DB, userID string, categoryID *string, payload *UpdateCategoryPayload, transactionType models.TransactionType) (*models.Category, error) {
 	if categoryID != nil {
 		category, err := s.getByIDWithDB(ctx, db, userID, *categoryID)
@@ -132,7 +580,36 @@ func (s *CategoryService) EnsureAndCreate(ctx context.Context, db *gorm.DB, user
 		input.Type = payload.Type
 	}
 
-	return s.createWithDB(ctx, db, userID, input)
+	exec := s.db
+	if db != nil {
+		exec = db
+	}
+
+	existing, err := findByNormalizedName(exec.WithContext(ctx), userID, input.Type, normalizeCategoryName(input.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return existing, nil
+	}
+
+	category, err := s.createWithDB(ctx, db, userID, input)
+	if apperror.Is(err, apperror.CategoryExists) {
+		// A concurrent request created it in the meantime.
+		existing, err := findByNormalizedName(exec.WithContext(ctx), userID, input.Type, normalizeCategoryName(input.Name))
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			return existing, nil
+		}
+
+		return nil, apperror.New(apperror.CategoryExists, nil)
+	}
+
+	return category, err
 }
 
 func (s *CategoryService) createWithDB(ctx context.Context, db *gorm.DB, userID string, input CreateCategoryInput) (*models.Category, error) {
@@ -140,20 +617,51 @@ func (s *CategoryService) createWithDB(ctx context.Context, db *gorm.DB, userID
 		return nil, apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
 	}
 
-	category := &models.Category{
-		CategoryID: uuid.NewString(),
-		Type:       input.Type,
-		Name:       input.Name,
-		Note:       input.Note,
-		UserID:     userID,
-	}
-
 	exec := s.db
 	if db != nil {
 		exec = db
 	}
 
-	if err := exec.WithContext(ctx).Create(category).Error; err != nil {
+	categoryID := uuid.NewString()
+	if input.ParentID != nil {
+		if err := validateParent(exec.WithContext(ctx), userID, categoryID, *input.ParentID, input.Type); err != nil {
+			return nil, err
+		}
+	}
+
+	// New categories go first, matching the newest-first order of the categories created before positions existed,
+	// which all sit at 0.
+	var position int
+	if err := exec.WithContext(ctx).Model(&models.Category{}).
+		Select("COALESCE(MIN(position), 1) - 1").
+		Where("user_id = ?", userID).
+		Scan(&position).Error; err != nil {
+		return nil, err
+	}
+
+	category := &models.Category{
+		CategoryID:     categoryID,
+		Type:           input.Type,
+		Name:           input.Name,
+		NormalizedName: normalizeCategoryName(input.Name),
+		Note:           input.Note,
+		ParentID:       input.ParentID,
+		Color:          input.Color,
+		Icon:           input.Icon,
+		Position:       position,
+		UserID:         userID,
+	}
+
+	// The insert runs in its own (nested) transaction so a violation of the name index, raced by a concurrent request
+	// past the checks above, leaves the caller's transaction usable to find out whether that was the cause.
+	if err := exec.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Create(category).Error
+	}); err != nil {
+		existing, findErr := findByNormalizedName(exec.WithContext(ctx), userID, category.Type, category.NormalizedName)
+		if findErr == nil && existing != nil {
+			return nil, apperror.New(apperror.CategoryExists, nil)
+		}
+
 		return nil, err
 	}
 
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+)
+
+// CategoryStats summarizes the transactions of a category.
+type CategoryStats struct {
+	TransactionCount int64            `json:"transactionCount"`
+	Totals           BalanceSummary   `json:"totals"`
+	Monthly          []MonthlyAmount  `json:"monthly"`
+	AveragePerMonth  float64          `json:"averagePerMonth"`
+	FirstTransaction *TransactionDate `json:"firstTransaction"`
+	LastTransaction  *TransactionDate `json:"lastTransaction"`
+}
+
+// MonthlyAmount is the converted total and transaction count of one month.
+type MonthlyAmount struct {
+	Year  int          `json:"year"`
+	Month models.Month `json:"month"`
+	Total float64      `json:"total"`
+	Count int64        `json:"count"`
+}
+
+// TransactionDate is the date of a transaction; Day is nil when the transaction only has month and year.
+type TransactionDate struct {
+	Day   *int         `json:"day"`
+	Month models.Month `json:"month"`
+	Year  int          `json:"year"`
+}
+
+// Stats returns the totals of a category (optionally with its subcategories), a zero-filled series of the last
+// months ending with the current one and the average of that series.
+func (s *CategoryService) Stats(ctx context.Context, userID, categoryID string, months int, includeSubcategories bool) (CategoryStats, error) {
+	if _, err := s.GetByID(ctx, userID, categoryID); err != nil {
+		return CategoryStats{}, err
+	}
+
+	categoryIDs := []string{categoryID}
+	if includeSubcategories {
+		var err error
+		categoryIDs, err = descendantIDs(s.db.WithContext(ctx), userID, categoryIDs)
+		if err != nil {
+			return CategoryStats{}, err
+		}
+	}
+
+	scope := func() *gorm.DB {
+		return s.db.WithContext(ctx).Model(&models.Transaction{}).
+			Where("transactions.user_id = ? AND transactions.category_id IN ?", userID, categoryIDs)
+	}
+
+	stats := CategoryStats{}
+
+	var currencies []struct {
+		Currency  models.Currency
+		Count     int64
+		Amount    float64
+		Converted float64
+	}
+	if err := scope().
+		Select("transactions.currency AS currency, COUNT(*) AS count, SUM(transactions.amount) AS amount, SUM(" + convertedAmountSQL + ") AS converted").
+		Group("transactions.currency").
+		Scan(&currencies).Error; err != nil {
+		return CategoryStats{}, err
+	}
+
+	for _, row := range currencies {
+		stats.TransactionCount += row.Count
+		stats.Totals.Total += row.Converted
+		switch row.Currency {
+		case models.CurrencyUYU:
+			stats.Totals.UYU += row.Amount
+		case models.CurrencyUSD:
+			stats.Totals.USD += row.Amount
+		case models.CurrencyEUR:
+			stats.Totals.EUR += row.Amount
+		}
+	}
+	stats.Totals = roundSummary(stats.Totals)
+
+	end := PeriodOf(time.Now())
+	start := end.AddMonths(1 - months)
+
+	var monthly []struct {
+		Year      int
+		Month     models.Month
+		Count     int64
+		Converted float64
+	}
+	if err := scope().
+		Select("transactions.year AS year, transactions.month AS month, COUNT(*) AS count, SUM("+convertedAmountSQL+") AS converted").
+		Where(periodKeySQL()+" BETWEEN ? AND ?", start.key(), end.key()).
+		Group("transactions.year, transactions.month").
+		Scan(&monthly).Error; err != nil {
+		return CategoryStats{}, err
+	}
+
+	byPeriod := make(map[Period]MonthlyAmount, len(monthly))
+	for _, row := range monthly {
+		byPeriod[Period{Year: row.Year, Month: row.Month}] = MonthlyAmount{Total: row.Converted, Count: row.Count}
+	}
+
+	var windowTotal float64
+	stats.Monthly = make([]MonthlyAmount, 0, months)
+	for _, p := range periodsBetween(start, end) {
+		amount := byPeriod[p]
+		windowTotal += amount.Total
+		stats.Monthly = append(stats.Monthly, MonthlyAmount{Year: p.Year, Month: p.Month, Total: round(amount.Total), Count: amount.Count})
+	}
+	stats.AveragePerMonth = round(windowTotal / float64(months))
+
+	var err error
+	if stats.FirstTransaction, err = s.boundaryTransaction(scope(), true); err != nil {
+		return CategoryStats{}, err
+	}
+
+	if stats.LastTransaction, err = s.boundaryTransaction(scope(), false); err != nil {
+		return CategoryStats{}, err
+	}
+
+	return stats, nil
+}
+
+// boundaryTransaction returns the date of the oldest (first=true) or newest transaction in scope.
+func (s *CategoryService) boundaryTransaction(scope *gorm.DB, first bool) (*TransactionDate, error) {
+	var transaction models.Transaction
+	if err := applyTransactionSort(scope, TransactionSort{Field: SortByDate, Ascending: first}).Take(&transaction).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &TransactionDate{Day: transaction.Day, Month: transaction.Month, Year: transaction.Year}, nil
+}
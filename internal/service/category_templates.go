@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// DefaultLocale is used when a template has no name for the requested locale.
+const DefaultLocale = "EN"
+
+// CategoryTemplate describes a category created when a template pack is applied, named per locale (EN/ES).
+type CategoryTemplate struct {
+	Type  models.TransactionType `json:"type"`
+	Names map[string]string      `json:"names"`
+}
+
+// CategoryTemplates maps a pack name to the categories it creates.
+type CategoryTemplates map[string][]CategoryTemplate
+
+var builtinCategoryTemplates = CategoryTemplates{
+	"basic": {
+		{Type: models.TransactionIncome, Names: map[string]string{"EN": "Salary", "ES": "Sueldo"}},
+		{Type: models.TransactionIncome, Names: map[string]string{"EN": "Other income", "ES": "Otros ingresos"}},
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Groceries", "ES": "Supermercado"}},
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Home", "ES": "Hogar"}},
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Utilities", "ES": "Servicios"}},
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Transport", "ES": "Transporte"}},
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Health", "ES": "Salud"}},
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Leisure", "ES": "Ocio"}},
+		{Type: models.TransactionSaving, Names: map[string]string{"EN": "Savings", "ES": "Ahorros"}},
+		{Type: models.TransactionInstallment, Names: map[string]string{"EN": "Credit card", "ES": "Tarjeta de crédito"}},
+	},
+	"family": {
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Education", "ES": "Educación"}},
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Kids", "ES": "Hijos"}},
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Pets", "ES": "Mascotas"}},
+		{Type: models.TransactionExpense, Names: map[string]string{"EN": "Clothing", "ES": "Ropa"}},
+	},
+}
+
+// DefaultCategoryTemplates returns the template packs bundled with the API.
+func DefaultCategoryTemplates() CategoryTemplates {
+	return builtinCategoryTemplates
+}
+
+// LoadCategoryTemplates reads template packs from a JSON file, falling back to the bundled ones when path is empty.
+func LoadCategoryTemplates(path string) (CategoryTemplates, error) {
+	if path == "" {
+		return DefaultCategoryTemplates(), nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates CategoryTemplates
+	if err := json.Unmarshal(raw, &templates); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	for pack, entries := range templates {
+		for idx, entry := range entries {
+			if _, ok := validTransactionTypes[entry.Type]; !ok {
+				return nil, fmt.Errorf("pack %q entry %d: invalid type %q", pack, idx, entry.Type)
+			}
+
+			if entry.Names[DefaultLocale] == "" {
+				return nil, fmt.Errorf("pack %q entry %d: missing %s name", pack, idx, DefaultLocale)
+			}
+		}
+	}
+
+	return templates, nil
+}
+
+// Name returns the template name for the locale, falling back to the default locale.
+func (t CategoryTemplate) Name(locale string) string {
+	if name, ok := t.Names[locale]; ok && name != "" {
+		return name
+	}
+
+	return t.Names[DefaultLocale]
+}
+
+// TemplatePacks returns the available pack names sorted alphabetically.
+func (s *CategoryService) TemplatePacks() []string {
+	packs := make([]string, 0, len(s.templates))
+	for pack := range s.templates {
+		packs = append(packs, pack)
+	}
+	sort.Strings(packs)
+
+	return packs
+}
+
+// Template returns the categories of a pack.
+func (s *CategoryService) Template(pack string) ([]CategoryTemplate, error) {
+	entries, ok := s.templates[pack]
+	if !ok {
+		return nil, apperror.New(apperror.CategoryTemplateNotFound, nil)
+	}
+
+	return entries, nil
+}
+
+// ApplyTemplate creates the categories of a pack for the user, reusing the ones that already exist by name.
+func (s *CategoryService) ApplyTemplate(ctx context.Context, db *gorm.DB, userID, pack, locale string) ([]models.Category, error) {
+	entries, err := s.Template(pack)
+	if err != nil {
+		return nil, err
+	}
+
+	exec := s.db
+	if db != nil {
+		exec = db
+	}
+
+	categories := make([]models.Category, 0, len(entries))
+	err = exec.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, entry := range entries {
+			name := entry.Name(locale)
+
+			existing, err := findByNormalizedName(tx, userID, entry.Type, normalizeCategoryName(name))
+			if err != nil {
+				return err
+			}
+
+			if existing != nil {
+				categories = append(categories, *existing)
+				continue
+			}
+
+			category, err := s.createWithDB(ctx, tx, userID, CreateCategoryInput{Type: entry.Type, Name: name})
+			if err != nil {
+				return err
+			}
+			categories = append(categories, *category)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// maxCategoryDepth bounds parent chain walks so corrupted data can never loop forever.
+const maxCategoryDepth = 32
+
+// CategoryNode is a category together with its subcategories.
+type CategoryNode struct {
+	Category models.Category
+	Children []*CategoryNode
+}
+
+// Tree returns the categories of the user nested under their parents, keeping the List order on every level.
+func (s *CategoryService) Tree(ctx context.Context, userID string, includeArchived bool) ([]*CategoryNode, error) {
+	categories, err := s.List(ctx, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildCategoryTree(categories), nil
+}
+
+// BuildCategoryTree nests the categories under their parents. Categories whose parent is missing from the list become roots.
+func BuildCategoryTree(categories []models.Category) []*CategoryNode {
+	nodes := make(map[string]*CategoryNode, len(categories))
+	for idx := range categories {
+		nodes[categories[idx].CategoryID] = &CategoryNode{Category: categories[idx]}
+	}
+
+	roots := make([]*CategoryNode, 0)
+	for idx := range categories {
+		node := nodes[categories[idx].CategoryID]
+		parentID := categories[idx].ParentID
+		if parentID != nil {
+			if parent, ok := nodes[*parentID]; ok && parent != node {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+
+		roots = append(roots, node)
+	}
+
+	return roots
+}
+
+// descendantIDs returns the given categories plus every subcategory below them.
+func descendantIDs(db *gorm.DB, userID string, rootIDs []string) ([]string, error) {
+	type link struct {
+		CategoryID string
+		ParentID   *string
+	}
+
+	var links []link
+	if err := db.Model(&models.Category{}).Select("category_id, parent_id").Where("user_id = ?", userID).Find(&links).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[string][]string)
+	for _, l := range links {
+		if l.ParentID != nil {
+			children[*l.ParentID] = append(children[*l.ParentID], l.CategoryID)
+		}
+	}
+
+	seen := make(map[string]struct{}, len(rootIDs))
+	result := make([]string, 0, len(rootIDs))
+	queue := append([]string(nil), rootIDs...)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[current]; ok {
+			continue
+		}
+
+		seen[current] = struct{}{}
+		result = append(result, current)
+		queue = append(queue, children[current]...)
+	}
+
+	return result, nil
+}
+
+// validateParent checks that the parent exists, shares the category type and is not the category or one of its descendants.
+func validateParent(db *gorm.DB, userID, categoryID, parentID string, categoryType models.TransactionType) error {
+	current := parentID
+	for depth := 0; depth < maxCategoryDepth; depth++ {
+		if current == categoryID {
+			return apperror.New(apperror.CategoryParentInvalid, "A category cannot be nested under itself or its subcategories")
+		}
+
+		var parent models.Category
+		if err := db.Where("category_id = ? AND user_id = ?", current, userID).Take(&parent).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if current == parentID {
+					return apperror.New(apperror.CategoryNotFound, nil)
+				}
+
+				return nil
+			}
+
+			return err
+		}
+
+		if current == parentID && parent.Type != categoryType {
+			return apperror.New(apperror.CategoryParentInvalid, "Parent category must have the same type")
+		}
+
+		if parent.ParentID == nil {
+			return nil
+		}
+
+		current = *parent.ParentID
+	}
+
+	return apperror.New(apperror.CategoryParentInvalid, "Category nesting is too deep")
+}
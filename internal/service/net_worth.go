@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// NetWorth splits the user's position at the end of a month, converted to UYU. There are no accounts, so cash is
+// every income minus every expense, installment and saving up to the month (it can be negative). Liabilities are the
+// installments dated after it.
+type NetWorth struct {
+	Period
+	Cash        float64 `json:"cash"`
+	Savings     float64 `json:"savings"`
+	Assets      float64 `json:"assets"`
+	Liabilities float64 `json:"liabilities"`
+	NetWorth    float64 `json:"netWorth"`
+}
+
+// NetWorth computes the net worth of the user at the end of the period.
+func (s *ReportService) NetWorth(ctx context.Context, userID string, at Period) (NetWorth, error) {
+	var rows []struct {
+		Type      models.TransactionType
+		Upcoming  bool
+		Converted float64
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Transaction{}).
+		Select("transactions.type AS type, CASE WHEN "+periodKeySQL()+" > ? THEN 1 ELSE 0 END AS upcoming, "+
+			"SUM("+convertedAmountSQL+") AS converted", at.key()).
+		Where("transactions.user_id = ?", userID).
+		Group("transactions.type, upcoming").
+		Scan(&rows).Error; err != nil {
+		return NetWorth{}, err
+	}
+
+	worth := NetWorth{Period: at}
+	for _, row := range rows {
+		if row.Upcoming {
+			if row.Type == models.TransactionInstallment {
+				worth.Liabilities += row.Converted
+			}
+			continue
+		}
+
+		switch row.Type {
+		case models.TransactionIncome:
+			worth.Cash += row.Converted
+		case models.TransactionExpense, models.TransactionInstallment:
+			worth.Cash -= row.Converted
+		case models.TransactionSaving:
+			worth.Cash -= row.Converted
+			worth.Savings += row.Converted
+		}
+	}
+
+	worth.Cash = round(worth.Cash)
+	worth.Savings = round(worth.Savings)
+	worth.Assets = round(worth.Cash + worth.Savings)
+	worth.Liabilities = round(worth.Liabilities)
+	worth.NetWorth = round(worth.Assets - worth.Liabilities)
+
+	return worth, nil
+}
+
+// NetWorthHistory returns the stored snapshots of the user between from and to (both included), oldest first.
+func (s *ReportService) NetWorthHistory(ctx context.Context, userID string, from, to Period) ([]NetWorth, error) {
+	if from.MonthsUntil(to) < 0 || from.MonthsUntil(to) >= MaxReportMonths {
+		return nil, apperror.New(apperror.ServerParamsMissing, "The range must span between 1 and 120 months")
+	}
+
+	snapshotKey := fmt.Sprintf("(year * 100 + %s)", monthNumberSQL("month"))
+
+	var snapshots []models.NetWorthSnapshot
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Where(snapshotKey+" BETWEEN ? AND ?", from.key(), to.key()).
+		Order(snapshotKey + " ASC").
+		Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+
+	history := make([]NetWorth, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		history = append(history, NetWorth{
+			Period:      Period{Year: snapshot.Year, Month: snapshot.Month},
+			Cash:        snapshot.Cash,
+			Savings:     snapshot.Savings,
+			Assets:      round(snapshot.Cash + snapshot.Savings),
+			Liabilities: snapshot.Liabilities,
+			NetWorth:    snapshot.NetWorth,
+		})
+	}
+
+	return history, nil
+}
+
+// SnapshotNetWorth stores the net worth of every user for the period, replacing the previous snapshot of that month.
+func (s *ReportService) SnapshotNetWorth(ctx context.Context, at Period) (int, error) {
+	var userIDs []string
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Pluck("user_id", &userIDs).Error; err != nil {
+		return 0, err
+	}
+
+	for _, userID := range userIDs {
+		if err := s.snapshotNetWorth(ctx, userID, at); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(userIDs), nil
+}
+
+// BackfillNetWorth stores the snapshots missing for every user from the month of their first transaction (at most
+// MaxReportMonths back) until the given period. It returns how many snapshots it created.
+func (s *ReportService) BackfillNetWorth(ctx context.Context, until Period) (int, error) {
+	var userIDs []string
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Pluck("user_id", &userIDs).Error; err != nil {
+		return 0, err
+	}
+
+	snapshotKey := fmt.Sprintf("(year * 100 + %s)", monthNumberSQL("month"))
+
+	created := 0
+	for _, userID := range userIDs {
+		var firstKey *int
+		if err := s.db.WithContext(ctx).Model(&models.Transaction{}).
+			Select("MIN("+periodKeySQL()+")").
+			Where("transactions.user_id = ?", userID).
+			Scan(&firstKey).Error; err != nil {
+			return 0, err
+		}
+
+		if firstKey == nil {
+			continue
+		}
+
+		start := Period{Year: *firstKey / 100, Month: models.Months[*firstKey%100-1]}
+		if start.MonthsUntil(until) >= MaxReportMonths {
+			start = until.AddMonths(1 - MaxReportMonths)
+		}
+
+		var storedKeys []int
+		if err := s.db.WithContext(ctx).Model(&models.NetWorthSnapshot{}).
+			Where("user_id = ?", userID).
+			Pluck(snapshotKey, &storedKeys).Error; err != nil {
+			return 0, err
+		}
+
+		stored := make(map[int]bool, len(storedKeys))
+		for _, key := range storedKeys {
+			stored[key] = true
+		}
+
+		for _, at := range periodsBetween(start, until) {
+			if stored[at.key()] {
+				continue
+			}
+
+			if err := s.snapshotNetWorth(ctx, userID, at); err != nil {
+				return 0, err
+			}
+			created++
+		}
+	}
+
+	return created, nil
+}
+
+// snapshotNetWorth stores the net worth of the user for the period, replacing the previous snapshot of that month.
+func (s *ReportService) snapshotNetWorth(ctx context.Context, userID string, at Period) error {
+	worth, err := s.NetWorth(ctx, userID, at)
+	if err != nil {
+		return err
+	}
+
+	snapshot := models.NetWorthSnapshot{
+		SnapshotID:  uuid.NewString(),
+		UserID:      userID,
+		Year:        at.Year,
+		Month:       at.Month,
+		Cash:        worth.Cash,
+		Savings:     worth.Savings,
+		Liabilities: worth.Liabilities,
+		NetWorth:    worth.NetWorth,
+	}
+
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "year"}, {Name: "month"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cash", "savings", "liabilities", "net_worth", "updated_at"}),
+	}).Create(&snapshot).Error
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/internal/oidc"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// oidcStateTTL is how long the user has to come back from the identity provider.
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCService signs users in through an OpenID Connect provider.
+type OIDCService struct {
+	db       *gorm.DB
+	redis    *redis.Client
+	provider *oidc.Provider
+	users    *UserService
+	auth     *AuthService
+}
+
+// oidcLogin is what a login keeps in Redis, under its state, while the user is at the provider.
+type oidcLogin struct {
+	CodeVerifier string `json:"codeVerifier"`
+	Nonce        string `json:"nonce"`
+}
+
+// NewOIDCService builds an OIDCService for the provider.
+func NewOIDCService(db *gorm.DB, redis *redis.Client, provider *oidc.Provider, users *UserService, auth *AuthService) *OIDCService {
+	return &OIDCService{db: db, redis: redis, provider: provider, users: users, auth: auth}
+}
+
+// Start begins a login and returns the provider URL to redirect to along with the state, which the caller binds to
+// the browser so the callback cannot be completed from another one.
+func (s *OIDCService) Start(ctx context.Context) (string, string, error) {
+	tokens := make([]string, 3)
+	for idx := range tokens {
+		token, err := randomToken()
+		if err != nil {
+			return "", "", err
+		}
+		tokens[idx] = token
+	}
+
+	state := tokens[0]
+	login := oidcLogin{CodeVerifier: tokens[1], Nonce: tokens[2]}
+
+	value, err := json.Marshal(login)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.redis.Set(ctx, s.stateKey(state), value, oidcStateTTL).Err(); err != nil {
+		return "", "", err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// Callback redeems the authorization code of the login started with state and opens a session for the linked user.
+// As with passwords, users with two-factor authentication get a pending session.
+func (s *OIDCService) Callback(ctx context.Context, state, code, previousSessionID string, info SessionInfo) (*models.User, string, error) {
+	if state == "" || code == "" {
+		return nil, "", apperror.New(apperror.AuthOIDCInvalid, nil)
+	}
+
+	value, err := s.redis.GetDel(ctx, s.stateKey(state)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, "", apperror.New(apperror.AuthOIDCInvalid, nil)
+		}
+
+		return nil, "", err
+	}
+
+	var login oidcLogin
+	if err := json.Unmarshal([]byte(value), &login); err != nil {
+		return nil, "", err
+	}
+
+	claims, err := s.provider.Exchange(ctx, code, login.CodeVerifier)
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidToken) || errors.Is(err, oidc.ErrRejected) {
+			return nil, "", apperror.New(apperror.AuthOIDCInvalid, nil)
+		}
+
+		return nil, "", err
+	}
+
+	if claims.Nonce != login.Nonce {
+		return nil, "", apperror.New(apperror.AuthOIDCInvalid, nil)
+	}
+
+	user, err := s.linkedUser(ctx, claims)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.auth.StartSession(ctx, user, previousSessionID, info)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, sessionID, nil
+}
+
+// linkedUser returns the user linked to the identity, linking it on first login to the user with the same email.
+// Both emails must be verified: otherwise whoever registered the address first would get the account.
+func (s *OIDCService) linkedUser(ctx context.Context, claims *oidc.Claims) (*models.User, error) {
+	now := time.Now()
+
+	var identity models.UserIdentity
+	err := s.db.WithContext(ctx).Where("issuer = ? AND subject = ?", claims.Issuer, claims.Subject).Take(&identity).Error
+	if err == nil {
+		user, err := s.users.GetByID(ctx, identity.UserID)
+		if err != nil {
+			if apperror.Is(err, apperror.UserNotFound) {
+				return nil, apperror.New(apperror.AuthOIDCUnlinked, nil)
+			}
+
+			return nil, err
+		}
+
+		if err := s.db.WithContext(ctx).Model(&identity).Updates(map[string]interface{}{
+			"email":         claims.Email,
+			"last_login_at": now,
+		}).Error; err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return nil, apperror.New(apperror.AuthOIDCUnlinked, nil)
+	}
+
+	user, err := s.users.FindByEmail(ctx, email)
+	if err != nil {
+		if apperror.Is(err, apperror.UserNotFound) {
+			return nil, apperror.New(apperror.AuthOIDCUnlinked, nil)
+		}
+
+		return nil, err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return nil, apperror.New(apperror.AuthEmailUnverified, nil)
+	}
+
+	identity = models.UserIdentity{
+		IdentityID:  uuid.NewString(),
+		UserID:      user.UserID,
+		Issuer:      claims.Issuer,
+		Subject:     claims.Subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}
+
+	if err := s.db.WithContext(ctx).Create(&identity).Error; err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *OIDCService) stateKey(state string) string {
+	return fmt.Sprintf("oidc-state:%s", state)
+}
@@ -0,0 +1,25 @@
+package service
+
+import "encoding/json"
+
+// OptionalString tells apart a JSON field that was omitted from one explicitly set to null.
+type OptionalString struct {
+	Set   bool
+	Value *string
+}
+
+func (o *OptionalString) UnmarshalJSON(b []byte) error {
+	o.Set = true
+
+	if string(b) == "null" {
+		o.Value = nil
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+	o.Value = &value
+	return nil
+}
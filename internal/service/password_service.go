@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/iperez/new-expenses-go/internal/mail"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// resetRequestInterval is the minimum time between two reset emails to the same user.
+const resetRequestInterval = time.Minute
+
+// PasswordService changes passwords and runs the password reset flow.
+type PasswordService struct {
+	users     *UserService
+	auth      *AuthService
+	redis     *redis.Client
+	mailer    mail.Mailer
+	validator *validator.Validate
+	resetTTL  time.Duration
+	appURL    string
+}
+
+// ChangePasswordInput carries the current password, required to set a new one.
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=6"`
+}
+
+// ResetPasswordInput carries the token received by email and the new password.
+type ResetPasswordInput struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,min=6"`
+}
+
+// NewPasswordService builds a PasswordService. Reset links point to appURL and stay valid for resetTTL.
+func NewPasswordService(users *UserService, auth *AuthService, redis *redis.Client, mailer mail.Mailer, resetTTL time.Duration, appURL string) *PasswordService {
+	return &PasswordService{
+		users:     users,
+		auth:      auth,
+		redis:     redis,
+		mailer:    mailer,
+		validator: validator.New(),
+		resetTTL:  resetTTL,
+		appURL:    appURL,
+	}
+}
+
+// Change sets a new password after checking the current one, ending every other session of the user.
+func (s *PasswordService) Change(ctx context.Context, userID, sessionID string, input ChangePasswordInput) error {
+	if err := s.validator.Struct(input); err != nil {
+		return apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)) != nil {
+		return apperror.New(apperror.AuthBadAuth, nil)
+	}
+
+	if err := s.users.setPassword(ctx, userID, input.NewPassword); err != nil {
+		return err
+	}
+
+	if err := s.revokeResetToken(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.auth.LogoutEverywhere(ctx, userID, sessionID)
+}
+
+// RequestReset emails a single-use reset link to the user, at most once per resetRequestInterval. Only the latest
+// link works. Unknown emails, throttled requests and mail failures are ignored so the response does not reveal which
+// accounts exist.
+func (s *PasswordService) RequestReset(ctx context.Context, email string) error {
+	user, err := s.users.FindByEmail(ctx, email)
+	if err != nil {
+		if apperror.Is(err, apperror.UserNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	allowed, err := s.redis.SetNX(ctx, s.throttleKey(user.UserID), 1, resetRequestInterval).Result()
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return nil
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	if err := s.revokeResetToken(ctx, user.UserID); err != nil {
+		return err
+	}
+
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.resetKey(token), user.UserID, s.resetTTL)
+		pipe.Set(ctx, s.userResetKey(user.UserID), s.resetKey(token), s.resetTTL)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.appURL, url.QueryEscape(token))
+
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the following link to choose a new password. It expires in %d minutes.\n\n%s\n\n"+
+			"If you did not ask for it, you can ignore this email.\n", user.FirstName, int(s.resetTTL.Minutes()), link),
+	})
+	if err != nil {
+		log.Printf("password reset email for user %s: %v", user.UserID, err)
+	}
+
+	return nil
+}
+
+// Reset consumes a reset token and sets the new password, ending every session of the user.
+func (s *PasswordService) Reset(ctx context.Context, input ResetPasswordInput) error {
+	if err := s.validator.Struct(input); err != nil {
+		return apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
+	}
+
+	userID, err := s.redis.GetDel(ctx, s.resetKey(input.Token)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return apperror.New(apperror.AuthResetInvalid, nil)
+		}
+
+		return err
+	}
+
+	if err := s.redis.Del(ctx, s.userResetKey(userID)).Err(); err != nil {
+		return err
+	}
+
+	if err := s.users.setPassword(ctx, userID, input.NewPassword); err != nil {
+		return err
+	}
+
+	return s.auth.LogoutEverywhere(ctx, userID, "")
+}
+
+// revokeResetToken invalidates the outstanding reset link of the user, if any.
+func (s *PasswordService) revokeResetToken(ctx context.Context, userID string) error {
+	resetKey, err := s.redis.GetDel(ctx, s.userResetKey(userID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+
+		return err
+	}
+
+	return s.redis.Del(ctx, resetKey).Err()
+}
+
+func (s *PasswordService) throttleKey(userID string) string {
+	return fmt.Sprintf("password-reset-sent:%s", userID)
+}
+
+// resetKey stores reset tokens hashed so a Redis dump does not expose usable links.
+func (s *PasswordService) resetKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("password-reset:%s", hex.EncodeToString(sum[:]))
+}
+
+// userResetKey points to the reset key of the user's latest link so issuing a new one can revoke it.
+func (s *PasswordService) userResetKey(userID string) string {
+	return fmt.Sprintf("password-reset-user:%s", userID)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+)
+
+// convertedAmountSQL converts a transaction amount to UYU the same way addToSummary does: USD/EUR use the stored
+// exchange rate and rows without one do not count towards the converted total.
+const convertedAmountSQL = "CASE WHEN transactions.currency = 'UYU' THEN transactions.amount " +
+	"WHEN transactions.exchange_rate IS NOT NULL THEN transactions.amount * transactions.exchange_rate ELSE 0 END"
+
+// Period identifies a calendar month.
+type Period struct {
+	Year  int          `json:"year"`
+	Month models.Month `json:"month"`
+}
+
+// PeriodOf returns the month the instant belongs to.
+func PeriodOf(t time.Time) Period {
+	return Period{Year: t.Year(), Month: models.Months[t.Month()-1]}
+}
+
+// periodFromIndex is the inverse of index.
+func periodFromIndex(index int) Period {
+	return Period{Year: index / 12, Month: models.Months[index%12]}
+}
+
+// index numbers months consecutively so periods can be iterated and subtracted.
+func (p Period) index() int {
+	return p.Year*12 + p.Month.Number() - 1
+}
+
+// key is the YYYYMM number matched by periodKeySQL.
+func (p Period) key() int {
+	return p.Year*100 + p.Month.Number()
+}
+
+// AddMonths returns the period the given number of months later (or earlier when negative).
+func (p Period) AddMonths(months int) Period {
+	return periodFromIndex(p.index() + months)
+}
+
+// MonthsUntil returns how many months separate p from end; it is negative when end comes first.
+func (p Period) MonthsUntil(end Period) int {
+	return end.index() - p.index()
+}
+
+// ParsePeriod reads a period written as YYYY-MM.
+func ParsePeriod(value string) (Period, error) {
+	parsed, err := time.Parse("2006-01", value)
+	if err != nil {
+		return Period{}, err
+	}
+
+	return PeriodOf(parsed), nil
+}
+
+// periodKeySQL computes the YYYYMM number of a transaction so month ranges can be filtered in SQL.
+func periodKeySQL() string {
+	return fmt.Sprintf("(transactions.year * 100 + %s)", monthNumberSQL("transactions.month"))
+}
+
+// periodsBetween lists every month from start to end, both included.
+func periodsBetween(start, end Period) []Period {
+	periods := make([]Period, 0, end.index()-start.index()+1)
+	for index := start.index(); index <= end.index(); index++ {
+		periods = append(periods, periodFromIndex(index))
+	}
+
+	return periods
+}
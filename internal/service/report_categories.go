@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+)
+
+// Breakdown bucket kinds.
+const (
+	BucketCategory      = "CATEGORY"
+	BucketUncategorized = "UNCATEGORIZED"
+	BucketOther         = "OTHER"
+)
+
+// CategoryBreakdown splits a total between categories, e.g. for pie charts.
+type CategoryBreakdown struct {
+	Total      float64         `json:"total"`
+	Count      int64           `json:"count"`
+	Categories []CategoryShare `json:"categories"`
+}
+
+// CategoryShare is the converted total of one bucket and its percentage of the breakdown total.
+type CategoryShare struct {
+	CategoryID *string `json:"categoryId"`
+	Name       string  `json:"name"`
+	Bucket     string  `json:"bucket"`
+	Total      float64 `json:"total"`
+	Share      float64 `json:"share"`
+	Count      int64   `json:"count"`
+}
+
+// BreakdownOptions tunes CategoryBreakdown. Top keeps the N biggest buckets and collapses the rest into "Other"
+// (0 keeps them all); Rollup adds subcategories to their top-level category.
+type BreakdownOptions struct {
+	Top    int
+	Rollup bool
+}
+
+// CategoryBreakdown groups the filtered transactions by category, biggest first. Transactions without category are
+// reported as a single uncategorized bucket. Types default to EXPENSE when the filters do not set any.
+func (s *ReportService) CategoryBreakdown(ctx context.Context, userID string, filters TransactionFilters, options BreakdownOptions) (CategoryBreakdown, error) {
+	shares, err := s.categoryTotals(ctx, userID, filters, options.Rollup)
+	if err != nil {
+		return CategoryBreakdown{}, err
+	}
+
+	breakdown := CategoryBreakdown{}
+	for _, share := range shares {
+		breakdown.Total += share.Total
+		breakdown.Count += share.Count
+	}
+
+	if options.Top > 0 && len(shares) > options.Top {
+		other := CategoryShare{Name: "Other", Bucket: BucketOther}
+		for _, share := range shares[options.Top:] {
+			other.Total += share.Total
+			other.Count += share.Count
+		}
+		shares = append(shares[:options.Top:options.Top], other)
+	}
+
+	for idx := range shares {
+		if breakdown.Total != 0 {
+			shares[idx].Share = round(shares[idx].Total / breakdown.Total * 100)
+		}
+		shares[idx].Total = round(shares[idx].Total)
+	}
+
+	breakdown.Total = round(breakdown.Total)
+	breakdown.Categories = shares
+
+	return breakdown, nil
+}
+
+// categoryTotals returns the unrounded converted total and count per category bucket, biggest first.
+func (s *ReportService) categoryTotals(ctx context.Context, userID string, filters TransactionFilters, rollup bool) ([]CategoryShare, error) {
+	if len(filters.Types) == 0 {
+		filters.Types = []models.TransactionType{models.TransactionExpense}
+	}
+
+	query, err := s.filtered(ctx, userID, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		CategoryID *string
+		Count      int64
+		Converted  float64
+	}
+	if err := query.
+		Select("transactions.category_id AS category_id, COUNT(*) AS count, SUM(" + convertedAmountSQL + ") AS converted").
+		Group("transactions.category_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byID, err := s.categoriesByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[string]*CategoryShare)
+	order := make([]string, 0, len(rows))
+	for _, row := range rows {
+		share := newCategoryShare(byID, row.CategoryID, rollup)
+		key := shareKey(share)
+
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &share
+			buckets[key] = bucket
+			order = append(order, key)
+		}
+
+		bucket.Total += row.Converted
+		bucket.Count += row.Count
+	}
+
+	shares := make([]CategoryShare, 0, len(order))
+	for _, key := range order {
+		shares = append(shares, *buckets[key])
+	}
+
+	sort.SliceStable(shares, func(i, j int) bool {
+		if shares[i].Total != shares[j].Total {
+			return shares[i].Total > shares[j].Total
+		}
+
+		return shares[i].Name < shares[j].Name
+	})
+
+	return shares, nil
+}
+
+// categoriesByID loads every category of the user, including archived and trashed ones since they still name their
+// historical transactions.
+func (s *ReportService) categoriesByID(ctx context.Context, userID string) (map[string]models.Category, error) {
+	var categories []models.Category
+	if err := s.db.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.Category, len(categories))
+	for _, category := range categories {
+		byID[category.CategoryID] = category
+	}
+
+	return byID, nil
+}
+
+// newCategoryShare returns the empty bucket a transaction category belongs to.
+func newCategoryShare(categories map[string]models.Category, categoryID *string, rollup bool) CategoryShare {
+	if categoryID == nil {
+		return CategoryShare{Name: "Uncategorized", Bucket: BucketUncategorized}
+	}
+
+	id := *categoryID
+	if rollup {
+		id = rootCategoryID(categories, id)
+	}
+
+	return CategoryShare{CategoryID: &id, Name: categories[id].Name, Bucket: BucketCategory}
+}
+
+// shareKey identifies a category bucket across queries; the uncategorized bucket has an empty key.
+func shareKey(share CategoryShare) string {
+	if share.CategoryID == nil {
+		return ""
+	}
+
+	return *share.CategoryID
+}
+
+// rootCategoryID walks up the parent chain and returns the top-level ancestor of the category.
+func rootCategoryID(categories map[string]models.Category, categoryID string) string {
+	current := categoryID
+	for depth := 0; depth < maxCategoryDepth; depth++ {
+		category, ok := categories[current]
+		if !ok || category.ParentID == nil {
+			return current
+		}
+
+		if _, ok := categories[*category.ParentID]; !ok {
+			return current
+		}
+
+		current = *category.ParentID
+	}
+
+	return current
+}
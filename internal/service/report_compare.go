@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+)
+
+// comparedTypes is the order in which type changes are reported.
+var comparedTypes = []models.TransactionType{
+	models.TransactionIncome,
+	models.TransactionExpense,
+	models.TransactionSaving,
+	models.TransactionInstallment,
+}
+
+// ComparedPeriod is the date selection of one side of a comparison.
+type ComparedPeriod struct {
+	Day   *int          `json:"day"`
+	Month *models.Month `json:"month"`
+	Year  *int          `json:"year"`
+}
+
+// Change compares a converted total between the current and the previous period. Percent is null when the previous
+// total is zero.
+type Change struct {
+	Current  float64  `json:"current"`
+	Previous float64  `json:"previous"`
+	Delta    float64  `json:"delta"`
+	Percent  *float64 `json:"percent"`
+}
+
+// TypeChange is the change of the total of one transaction type.
+type TypeChange struct {
+	Type models.TransactionType `json:"type"`
+	Change
+}
+
+// CategoryChange is the change of one category bucket. Appeared and Disappeared flag categories with transactions in
+// only one of the periods.
+type CategoryChange struct {
+	CategoryID  *string `json:"categoryId"`
+	Name        string  `json:"name"`
+	Bucket      string  `json:"bucket"`
+	Appeared    bool    `json:"appeared"`
+	Disappeared bool    `json:"disappeared"`
+	Change
+}
+
+// PeriodComparison holds the per-type and per-category changes between two periods.
+type PeriodComparison struct {
+	Current    ComparedPeriod   `json:"current"`
+	Previous   ComparedPeriod   `json:"previous"`
+	Types      []TypeChange     `json:"types"`
+	Categories []CategoryChange `json:"categories"`
+}
+
+// PreviousPeriod shifts the date filters to the period right before the selected one: the previous month when month
+// and year are set, the previous year when only the year is. It returns false when there is no such period.
+func PreviousPeriod(filters TransactionFilters) (TransactionFilters, bool) {
+	switch {
+	case filters.Month != nil && filters.Year != nil:
+		previous := Period{Year: *filters.Year, Month: *filters.Month}.AddMonths(-1)
+		filters.Month, filters.Year = &previous.Month, &previous.Year
+	case filters.Month == nil && filters.Year != nil:
+		year := *filters.Year - 1
+		filters.Year = &year
+	default:
+		return filters, false
+	}
+
+	return filters, true
+}
+
+// Compare returns the converted totals of two periods and their differences. Types cover every type unless the
+// filters select some; categories, like CategoryBreakdown, default to EXPENSE.
+func (s *ReportService) Compare(ctx context.Context, userID string, current, previous TransactionFilters, rollup bool) (PeriodComparison, error) {
+	comparison := PeriodComparison{
+		Current:  ComparedPeriod{Day: current.Day, Month: current.Month, Year: current.Year},
+		Previous: ComparedPeriod{Day: previous.Day, Month: previous.Month, Year: previous.Year},
+	}
+
+	currentTypes, err := s.typeTotals(ctx, userID, current)
+	if err != nil {
+		return PeriodComparison{}, err
+	}
+
+	previousTypes, err := s.typeTotals(ctx, userID, previous)
+	if err != nil {
+		return PeriodComparison{}, err
+	}
+
+	types := current.Types
+	if len(types) == 0 {
+		types = comparedTypes
+	}
+
+	for _, transactionType := range types {
+		comparison.Types = append(comparison.Types, TypeChange{
+			Type:   transactionType,
+			Change: newChange(currentTypes[transactionType], previousTypes[transactionType]),
+		})
+	}
+
+	currentShares, err := s.categoryTotals(ctx, userID, current, rollup)
+	if err != nil {
+		return PeriodComparison{}, err
+	}
+
+	previousShares, err := s.categoryTotals(ctx, userID, previous, rollup)
+	if err != nil {
+		return PeriodComparison{}, err
+	}
+
+	previousByKey := make(map[string]CategoryShare, len(previousShares))
+	for _, share := range previousShares {
+		previousByKey[shareKey(share)] = share
+	}
+
+	comparison.Categories = make([]CategoryChange, 0, len(currentShares))
+	for _, share := range currentShares {
+		before, existed := previousByKey[shareKey(share)]
+		delete(previousByKey, shareKey(share))
+
+		comparison.Categories = append(comparison.Categories, CategoryChange{
+			CategoryID: share.CategoryID,
+			Name:       share.Name,
+			Bucket:     share.Bucket,
+			Appeared:   !existed,
+			Change:     newChange(share.Total, before.Total),
+		})
+	}
+
+	for _, share := range previousShares {
+		if _, pending := previousByKey[shareKey(share)]; !pending {
+			continue
+		}
+
+		comparison.Categories = append(comparison.Categories, CategoryChange{
+			CategoryID:  share.CategoryID,
+			Name:        share.Name,
+			Bucket:      share.Bucket,
+			Disappeared: true,
+			Change:      newChange(0, share.Total),
+		})
+	}
+
+	sort.SliceStable(comparison.Categories, func(i, j int) bool {
+		if comparison.Categories[i].Current != comparison.Categories[j].Current {
+			return comparison.Categories[i].Current > comparison.Categories[j].Current
+		}
+
+		return comparison.Categories[i].Previous > comparison.Categories[j].Previous
+	})
+
+	return comparison, nil
+}
+
+// typeTotals returns the unrounded converted total of each transaction type selected by the filters.
+func (s *ReportService) typeTotals(ctx context.Context, userID string, filters TransactionFilters) (map[models.TransactionType]float64, error) {
+	query, err := s.filtered(ctx, userID, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Type      models.TransactionType
+		Converted float64
+	}
+	if err := query.
+		Select("transactions.type AS type, SUM(" + convertedAmountSQL + ") AS converted").
+		Group("transactions.type").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	totals := make(map[models.TransactionType]float64, len(rows))
+	for _, row := range rows {
+		totals[row.Type] = row.Converted
+	}
+
+	return totals, nil
+}
+
+func newChange(current, previous float64) Change {
+	change := Change{Current: round(current), Previous: round(previous), Delta: round(current - previous)}
+	if previous != 0 {
+		percent := round((current - previous) / previous * 100)
+		change.Percent = &percent
+	}
+
+	return change
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// ForecastEntry projects the end-of-month spending of one category bucket.
+type ForecastEntry struct {
+	CategoryID        *string `json:"categoryId"`
+	Name              string  `json:"name"`
+	Bucket            string  `json:"bucket"`
+	SpentToDate       float64 `json:"spentToDate"`
+	Scheduled         float64 `json:"scheduled"`
+	RunRate           float64 `json:"runRate"`
+	HistoricalAverage float64 `json:"historicalAverage"`
+	Projected         float64 `json:"projected"`
+}
+
+// ForecastTotals adds up the entries of a forecast.
+type ForecastTotals struct {
+	SpentToDate       float64 `json:"spentToDate"`
+	Scheduled         float64 `json:"scheduled"`
+	HistoricalAverage float64 `json:"historicalAverage"`
+	Projected         float64 `json:"projected"`
+}
+
+// Forecast projects the spending of the month containing Date.
+type Forecast struct {
+	Period
+	Date          string          `json:"date"`
+	Elapsed       float64         `json:"elapsed"`
+	HistoryMonths int             `json:"historyMonths"`
+	Totals        ForecastTotals  `json:"totals"`
+	Categories    []ForecastEntry `json:"categories"`
+}
+
+// Forecast projects the end-of-month spending per category for the month containing today.
+//
+// Expenses dated up to today (or without day) are spent; the run-rate extrapolates them to the whole month and is
+// blended with the average of the previous historyMonths, weighting the run-rate by the elapsed share of the month.
+// Expenses dated after today and the month's installments are known payments added on top of the blend. There are no
+// recurring transactions yet, so future payments only come from rows the user already entered.
+func (s *ReportService) Forecast(ctx context.Context, userID string, today time.Time, historyMonths int, filters TransactionFilters) (Forecast, error) {
+	if historyMonths < 1 || historyMonths > MaxReportMonths {
+		return Forecast{}, apperror.New(apperror.ServerParamsMissing, "The history must span between 1 and 120 months")
+	}
+
+	current := PeriodOf(today)
+	daysInMonth := time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, today.Location()).Day()
+	elapsed := float64(today.Day()) / float64(daysInMonth)
+
+	forecast := Forecast{
+		Period:        current,
+		Date:          today.Format(time.DateOnly),
+		Elapsed:       round(elapsed),
+		HistoryMonths: historyMonths,
+	}
+
+	filters.Types = nil
+	byID, err := s.categoriesByID(ctx, userID)
+	if err != nil {
+		return Forecast{}, err
+	}
+
+	entries := make(map[string]*ForecastEntry)
+	hasHistory := make(map[string]bool)
+	entryFor := func(categoryID *string) (string, *ForecastEntry) {
+		share := newCategoryShare(byID, categoryID, false)
+		key := shareKey(share)
+
+		entry, ok := entries[key]
+		if !ok {
+			entry = &ForecastEntry{CategoryID: share.CategoryID, Name: share.Name, Bucket: share.Bucket}
+			entries[key] = entry
+		}
+
+		return key, entry
+	}
+
+	query, err := s.scope(ctx, userID, filters)
+	if err != nil {
+		return Forecast{}, err
+	}
+
+	var monthRows []struct {
+		CategoryID *string
+		Type       models.TransactionType
+		Upcoming   bool
+		Converted  float64
+	}
+	if err := query.
+		Select("transactions.category_id AS category_id, transactions.type AS type, "+
+			"CASE WHEN transactions.day > ? THEN 1 ELSE 0 END AS upcoming, SUM("+convertedAmountSQL+") AS converted", today.Day()).
+		Where("transactions.type IN ?", []models.TransactionType{models.TransactionExpense, models.TransactionInstallment}).
+		Where("transactions.year = ? AND transactions.month = ?", current.Year, current.Month).
+		Group("transactions.category_id, transactions.type, upcoming").
+		Scan(&monthRows).Error; err != nil {
+		return Forecast{}, err
+	}
+
+	for _, row := range monthRows {
+		_, entry := entryFor(row.CategoryID)
+		if row.Type == models.TransactionInstallment || row.Upcoming {
+			entry.Scheduled += row.Converted
+		} else {
+			entry.SpentToDate += row.Converted
+		}
+	}
+
+	if query, err = s.scope(ctx, userID, filters); err != nil {
+		return Forecast{}, err
+	}
+
+	var historyRows []struct {
+		CategoryID *string
+		Converted  float64
+	}
+	if err := query.
+		Select("transactions.category_id AS category_id, SUM("+convertedAmountSQL+") AS converted").
+		Where("transactions.type = ?", models.TransactionExpense).
+		Where(periodKeySQL()+" BETWEEN ? AND ?", current.AddMonths(-historyMonths).key(), current.AddMonths(-1).key()).
+		Group("transactions.category_id").
+		Scan(&historyRows).Error; err != nil {
+		return Forecast{}, err
+	}
+
+	for _, row := range historyRows {
+		key, entry := entryFor(row.CategoryID)
+		entry.HistoricalAverage = row.Converted / float64(historyMonths)
+		hasHistory[key] = true
+	}
+
+	forecast.Categories = make([]ForecastEntry, 0, len(entries))
+	for key, entry := range entries {
+		entry.RunRate = entry.SpentToDate / elapsed
+
+		// Categories without history can only be projected from this month.
+		weight := elapsed
+		if !hasHistory[key] {
+			weight = 1
+		}
+
+		variable := weight*entry.RunRate + (1-weight)*entry.HistoricalAverage
+		if variable < entry.SpentToDate {
+			variable = entry.SpentToDate
+		}
+		entry.Projected = variable + entry.Scheduled
+
+		forecast.Totals.SpentToDate += entry.SpentToDate
+		forecast.Totals.Scheduled += entry.Scheduled
+		forecast.Totals.HistoricalAverage += entry.HistoricalAverage
+		forecast.Totals.Projected += entry.Projected
+
+		forecast.Categories = append(forecast.Categories, ForecastEntry{
+			CategoryID:        entry.CategoryID,
+			Name:              entry.Name,
+			Bucket:            entry.Bucket,
+			SpentToDate:       round(entry.SpentToDate),
+			Scheduled:         round(entry.Scheduled),
+			RunRate:           round(entry.RunRate),
+			HistoricalAverage: round(entry.HistoricalAverage),
+			Projected:         round(entry.Projected),
+		})
+	}
+
+	sort.Slice(forecast.Categories, func(i, j int) bool {
+		if forecast.Categories[i].Projected != forecast.Categories[j].Projected {
+			return forecast.Categories[i].Projected > forecast.Categories[j].Projected
+		}
+
+		return forecast.Categories[i].Name < forecast.Categories[j].Name
+	})
+
+	forecast.Totals = ForecastTotals{
+		SpentToDate:       round(forecast.Totals.SpentToDate),
+		Scheduled:         round(forecast.Totals.Scheduled),
+		HistoricalAverage: round(forecast.Totals.HistoricalAverage),
+		Projected:         round(forecast.Totals.Projected),
+	}
+
+	return forecast, nil
+}
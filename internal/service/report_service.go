@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// MaxReportMonths caps the length of time-series reports.
+const MaxReportMonths = 120
+
+// ReportService aggregates transactions into reports using SQL grouping.
+type ReportService struct {
+	db           *gorm.DB
+	transactions *TransactionService
+}
+
+// MonthlyReportEntry holds the converted totals of one month. Expenses include installments, and Net is the
+// cashflow (incomes minus expenses); savings stay out of it because they remain the user's money.
+type MonthlyReportEntry struct {
+	Period
+	Incomes  float64 `json:"incomes"`
+	Expenses float64 `json:"expenses"`
+	Savings  float64 `json:"savings"`
+	Net      float64 `json:"net"`
+}
+
+func NewReportService(db *gorm.DB, transactions *TransactionService) *ReportService {
+	return &ReportService{db: db, transactions: transactions}
+}
+
+// Monthly returns the per-month totals, converted to UYU, between from and to (both included). Months without
+// transactions are filled with zeros. The day/month/year filters are replaced by the range.
+func (s *ReportService) Monthly(ctx context.Context, userID string, from, to Period, filters TransactionFilters) ([]MonthlyReportEntry, error) {
+	if from.MonthsUntil(to) < 0 || from.MonthsUntil(to) >= MaxReportMonths {
+		return nil, apperror.New(apperror.ServerParamsMissing, "The range must span between 1 and 120 months")
+	}
+
+	query, err := s.scope(ctx, userID, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Year      int
+		Month     models.Month
+		Type      models.TransactionType
+		Converted float64
+	}
+	if err := query.
+		Select("transactions.year AS year, transactions.month AS month, transactions.type AS type, SUM("+convertedAmountSQL+") AS converted").
+		Where(periodKeySQL()+" BETWEEN ? AND ?", from.key(), to.key()).
+		Group("transactions.year, transactions.month, transactions.type").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byPeriod := make(map[Period]*MonthlyReportEntry)
+	entries := make([]MonthlyReportEntry, 0, from.MonthsUntil(to)+1)
+	for _, p := range periodsBetween(from, to) {
+		entries = append(entries, MonthlyReportEntry{Period: p})
+	}
+	for idx := range entries {
+		byPeriod[entries[idx].Period] = &entries[idx]
+	}
+
+	for _, row := range rows {
+		entry, ok := byPeriod[Period{Year: row.Year, Month: row.Month}]
+		if !ok {
+			continue
+		}
+
+		switch row.Type {
+		case models.TransactionExpense, models.TransactionInstallment:
+			entry.Expenses += row.Converted
+		case models.TransactionIncome:
+			entry.Incomes += row.Converted
+		case models.TransactionSaving:
+			entry.Savings += row.Converted
+		}
+	}
+
+	for idx := range entries {
+		entries[idx].Incomes = round(entries[idx].Incomes)
+		entries[idx].Expenses = round(entries[idx].Expenses)
+		entries[idx].Savings = round(entries[idx].Savings)
+		entries[idx].Net = round(entries[idx].Incomes - entries[idx].Expenses)
+	}
+
+	return entries, nil
+}
+
+// scope starts a transactions query for the user with every non-date filter applied.
+func (s *ReportService) scope(ctx context.Context, userID string, filters TransactionFilters) (*gorm.DB, error) {
+	filters.Day, filters.Month, filters.Year = nil, nil, nil
+
+	return s.filtered(ctx, userID, filters)
+}
+
+// filtered starts a transactions query for the user with every filter applied.
+func (s *ReportService) filtered(ctx context.Context, userID string, filters TransactionFilters) (*gorm.DB, error) {
+	filters, err := s.transactions.expandSubcategories(ctx, userID, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.Transaction{}).Where("transactions.user_id = ?", userID)
+	return applyTransactionFilters(query, filters), nil
+}
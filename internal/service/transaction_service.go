@@ -10,6 +10,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/iperez/new-expenses-go/internal/domain/models"
 	"github.com/iperez/new-expenses-go/pkg/apperror"
@@ -37,11 +38,35 @@ type CreateTransactionInput struct {
 }
 
 // TransactionFilters encapsulates the optional parameters supported by list/balance endpoints.
+// Slice filters match any of their values; Uncategorized combined with CategoryIDs matches either.
+// IncludeSubcategories extends CategoryIDs with every subcategory so totals roll up to the parents.
 type TransactionFilters struct {
-	Type  *models.TransactionType
-	Day   *int
-	Month *models.Month
-	Year  *int
+	Types                []models.TransactionType
+	Currencies           []models.Currency
+	CategoryIDs          []string
+	IncludeSubcategories bool
+	Uncategorized        bool
+	MinAmount            *float64
+	MaxAmount            *float64
+	Day                  *int
+	Month                *models.Month
+	Year                 *int
+}
+
+// TransactionSortField enumerates the fields accepted when ordering transactions.
+type TransactionSortField string
+
+const (
+	SortByDate     TransactionSortField = "date"
+	SortByAmount   TransactionSortField = "amount"
+	SortByCategory TransactionSortField = "category"
+	SortByCreated  TransactionSortField = "created"
+)
+
+// TransactionSort describes how List orders its results. The zero value sorts by creation time, newest first.
+type TransactionSort struct {
+	Field     TransactionSortField
+	Ascending bool
 }
 
 // BalanceSummary represents the total amount per currency.
@@ -99,7 +124,7 @@ func (s *TransactionService) Create(ctx context.Context, userID string, payloads
 				transaction.Category = category
 			}
 
-			if err := tx.Create(&transaction).Error; err != nil {
+			if err := tx.Omit(clause.Associations).Create(&transaction).Error; err != nil {
 				return err
 			}
 
@@ -241,34 +266,149 @@ func ValidMonths() map[models.Month]struct{} {
 	return validMonths
 }
 
-// List returns every transaction that matches the provided filters.
-func (s *TransactionService) List(ctx context.Context, userID string, filters TransactionFilters) ([]models.Transaction, error) {
-	query := s.db.WithContext(ctx).Where("user_id = ?", userID)
+// ValidCurrencies exposes the supported currencies.
+func ValidCurrencies() map[models.Currency]struct{} {
+	return validCurrencies
+}
 
-	if filters.Type != nil {
-		query = query.Where("type = ?", *filters.Type)
-	}
+// ValidSortFields exposes the fields accepted by the sort query parameter.
+func ValidSortFields() map[TransactionSortField]struct{} {
+	return validSortFields
+}
 
-	if filters.Day != nil {
-		query = query.Where("day = ?", *filters.Day)
-	}
+// sortExpressions whitelists the SQL used for every sort field so user input never reaches the ORDER BY clause.
+var sortExpressions = map[TransactionSortField][]string{
+	SortByDate:     {"transactions.year", monthNumberSQL("transactions.month"), "COALESCE(transactions.day, 0)"},
+	SortByAmount:   {"transactions.amount"},
+	SortByCategory: {"COALESCE(LOWER(categories.name), '')"},
+	SortByCreated:  {"transactions.created_at"},
+}
 
-	if filters.Month != nil {
-		query = query.Where("month = ?", *filters.Month)
+var validSortFields = map[TransactionSortField]struct{}{
+	SortByDate:     {},
+	SortByAmount:   {},
+	SortByCategory: {},
+	SortByCreated:  {},
+}
+
+// monthNumberSQL maps a month column to its calendar number (1-12) so it can be ordered or compared.
+func monthNumberSQL(column string) string {
+	var builder strings.Builder
+	builder.WriteString("CASE ")
+	builder.WriteString(column)
+	for _, month := range models.Months {
+		builder.WriteString(fmt.Sprintf(" WHEN '%s' THEN %d", month, month.Number()))
 	}
+	builder.WriteString(" ELSE 0 END")
 
-	if filters.Year != nil {
-		query = query.Where("year = ?", *filters.Year)
+	return builder.String()
+}
+
+// List returns every transaction that matches the provided filters in the requested order.
+func (s *TransactionService) List(ctx context.Context, userID string, filters TransactionFilters, order TransactionSort) ([]models.Transaction, error) {
+	filters, err := s.expandSubcategories(ctx, userID, filters)
+	if err != nil {
+		return nil, err
 	}
 
+	query := applyTransactionFilters(s.db.WithContext(ctx).Where("transactions.user_id = ?", userID), filters)
+	query = applyTransactionSort(query, order)
+
 	var transactions []models.Transaction
-	if err := query.Preload("Category").Order("created_at DESC").Find(&transactions).Error; err != nil {
+	if err := query.Preload("Category").Find(&transactions).Error; err != nil {
 		return nil, err
 	}
 
 	return transactions, nil
 }
 
+// applyTransactionSort orders the query and appends creation time and ID as tie-breakers to keep pages stable.
+func applyTransactionSort(query *gorm.DB, order TransactionSort) *gorm.DB {
+	field := order.Field
+	if _, ok := validSortFields[field]; !ok {
+		field = SortByCreated
+	}
+
+	direction := "DESC"
+	if order.Ascending {
+		direction = "ASC"
+	}
+
+	if field == SortByCategory {
+		// Trashed categories sort with the uncategorized rows, as they are shown.
+		query = query.Joins("LEFT JOIN categories ON categories.category_id = transactions.category_id AND categories.deleted_at IS NULL")
+	}
+
+	for _, expression := range sortExpressions[field] {
+		query = query.Order(expression + " " + direction)
+	}
+
+	if field != SortByCreated {
+		query = query.Order("transactions.created_at " + direction)
+	}
+
+	return query.Order("transactions.transaction_id " + direction)
+}
+
+// expandSubcategories resolves IncludeSubcategories into the full list of category IDs.
+func (s *TransactionService) expandSubcategories(ctx context.Context, userID string, filters TransactionFilters) (TransactionFilters, error) {
+	if !filters.IncludeSubcategories || len(filters.CategoryIDs) == 0 {
+		return filters, nil
+	}
+
+	categoryIDs, err := descendantIDs(s.db.WithContext(ctx), userID, filters.CategoryIDs)
+	if err != nil {
+		return filters, err
+	}
+
+	filters.CategoryIDs = categoryIDs
+	filters.IncludeSubcategories = false
+
+	return filters, nil
+}
+
+// applyTransactionFilters narrows a transactions query so every read endpoint filters the same way.
+func applyTransactionFilters(query *gorm.DB, filters TransactionFilters) *gorm.DB {
+	if len(filters.Types) > 0 {
+		query = query.Where("transactions.type IN ?", filters.Types)
+	}
+
+	if len(filters.Currencies) > 0 {
+		query = query.Where("transactions.currency IN ?", filters.Currencies)
+	}
+
+	switch {
+	case len(filters.CategoryIDs) > 0 && filters.Uncategorized:
+		query = query.Where("(transactions.category_id IN ? OR transactions.category_id IS NULL)", filters.CategoryIDs)
+	case len(filters.CategoryIDs) > 0:
+		query = query.Where("transactions.category_id IN ?", filters.CategoryIDs)
+	case filters.Uncategorized:
+		query = query.Where("transactions.category_id IS NULL")
+	}
+
+	if filters.MinAmount != nil {
+		query = query.Where("transactions.amount >= ?", *filters.MinAmount)
+	}
+
+	if filters.MaxAmount != nil {
+		query = query.Where("transactions.amount <= ?", *filters.MaxAmount)
+	}
+
+	if filters.Day != nil {
+		query = query.Where("transactions.day = ?", *filters.Day)
+	}
+
+	if filters.Month != nil {
+		query = query.Where("transactions.month = ?", *filters.Month)
+	}
+
+	if filters.Year != nil {
+		query = query.Where("transactions.year = ?", *filters.Year)
+	}
+
+	return query
+}
+
 func (s *TransactionService) GetByID(ctx context.Context, userID, transactionID string) (*models.Transaction, error) {
 	var transaction models.Transaction
 	if err := s.db.WithContext(ctx).
@@ -302,7 +442,7 @@ func (s *TransactionService) Delete(ctx context.Context, userID, transactionID s
 }
 
 func (s *TransactionService) Balances(ctx context.Context, userID string, filters TransactionFilters) (TransactionBalances, error) {
-	transactions, err := s.List(ctx, userID, filters)
+	transactions, err := s.List(ctx, userID, filters, TransactionSort{})
 	if err != nil {
 		return TransactionBalances{}, err
 	}
@@ -340,6 +480,10 @@ func addToSummary(summary BalanceSummary, trx models.Transaction) BalanceSummary
 		}
 	}
 
+	return roundSummary(summary)
+}
+
+func roundSummary(summary BalanceSummary) BalanceSummary {
 	summary.Total = round(summary.Total)
 	summary.UYU = round(summary.UYU)
 	summary.USD = round(summary.USD)
@@ -395,28 +539,9 @@ func containsMonth(list []models.Month, month models.Month) bool {
 
 func sortMonths(months []models.Month) []models.Month {
 	ordered := append([]models.Month(nil), months...)
-	order := []models.Month{
-		models.MonthJanuary,
-		models.MonthFebruary,
-		models.MonthMarch,
-		models.MonthApril,
-		models.MonthMay,
-		models.MonthJune,
-		models.MonthJuly,
-		models.MonthAugust,
-		models.MonthSeptember,
-		models.MonthOctober,
-		models.MonthNovember,
-		models.MonthDecember,
-	}
-
-	sortIndex := make(map[models.Month]int)
-	for idx, month := range order {
-		sortIndex[month] = idx
-	}
 
 	sort.Slice(ordered, func(i, j int) bool {
-		return sortIndex[ordered[i]] < sortIndex[ordered[j]]
+		return ordered[i].Number() < ordered[j].Number()
 	})
 
 	return ordered
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// TrashService exposes the soft-deleted categories and transactions so they can be restored or purged.
+type TrashService struct {
+	db *gorm.DB
+}
+
+// TrashItemType tells which table a trashed item belongs to.
+type TrashItemType string
+
+const (
+	TrashItemTransaction TrashItemType = "TRANSACTION"
+	TrashItemCategory    TrashItemType = "CATEGORY"
+)
+
+// TrashContents groups the soft-deleted rows of a user.
+type TrashContents struct {
+	Categories   []models.Category
+	Transactions []models.Transaction
+}
+
+// NewTrashService builds a TrashService backed by the provided database handle.
+func NewTrashService(db *gorm.DB) *TrashService {
+	return &TrashService{db: db}
+}
+
+// List returns every soft-deleted category and transaction of the user, most recently deleted first.
+func (s *TrashService) List(ctx context.Context, userID string) (TrashContents, error) {
+	contents := TrashContents{}
+
+	if err := s.db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").
+		Find(&contents.Categories).Error; err != nil {
+		return TrashContents{}, err
+	}
+
+	if err := s.db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Preload("Category", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Order("deleted_at DESC").
+		Find(&contents.Transactions).Error; err != nil {
+		return TrashContents{}, err
+	}
+
+	return contents, nil
+}
+
+// Restore brings a trashed transaction or category back. Restoring a transaction also restores its trashed category,
+// and restoring a category re-links the transactions its deletion left uncategorized.
+func (s *TrashService) Restore(ctx context.Context, userID, itemID string) (TrashItemType, error) {
+	var restored TrashItemType
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		transaction, err := findTrashedTransaction(tx, userID, itemID)
+		if err != nil {
+			return err
+		}
+
+		if transaction != nil {
+			if transaction.CategoryID != nil {
+				if err := restoreTrashedCategory(tx, userID, *transaction.CategoryID); err != nil {
+					return err
+				}
+			}
+
+			restored = TrashItemTransaction
+			return tx.Unscoped().Model(&models.Transaction{}).
+				Where("transaction_id = ? AND user_id = ?", itemID, userID).
+				Update("deleted_at", nil).Error
+		}
+
+		category, err := findTrashedCategory(tx, userID, itemID)
+		if err != nil {
+			return err
+		}
+
+		if category == nil {
+			return apperror.New(apperror.TrashItemNotFound, nil)
+		}
+
+		restored = TrashItemCategory
+		return restoreTrashedCategory(tx, userID, itemID)
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return restored, nil
+}
+
+// restoreTrashedCategory reverses the latest deletion of the category. Trash restores are not bound to the undo window.
+func restoreTrashedCategory(tx *gorm.DB, userID, categoryID string) error {
+	deletion, err := latestCategoryDeletion(tx, userID, categoryID)
+	if err != nil {
+		return err
+	}
+
+	_, err = reverseCategoryDeletion(tx, userID, categoryID, deletion, false)
+	return err
+}
+
+// Purge permanently deletes a trashed transaction or category, the latter with the transactions trashed along with it.
+func (s *TrashService) Purge(ctx context.Context, userID, itemID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		transaction, err := findTrashedTransaction(tx, userID, itemID)
+		if err != nil {
+			return err
+		}
+
+		if transaction != nil {
+			return tx.Unscoped().Delete(transaction).Error
+		}
+
+		category, err := findTrashedCategory(tx, userID, itemID)
+		if err != nil {
+			return err
+		}
+
+		if category == nil {
+			return apperror.New(apperror.TrashItemNotFound, nil)
+		}
+
+		_, err = purgeCategories(tx, []string{category.CategoryID})
+		return err
+	})
+}
+
+// PurgeExpired permanently deletes every item, of any user, that was trashed before the provided instant.
+func (s *TrashService) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Delete(&models.Transaction{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged += result.RowsAffected
+
+		var categoryIDs []string
+		if err := tx.Unscoped().Model(&models.Category{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Pluck("category_id", &categoryIDs).Error; err != nil {
+			return err
+		}
+
+		if len(categoryIDs) == 0 {
+			return nil
+		}
+
+		transactions, err := purgeCategories(tx, categoryIDs)
+		purged += int64(len(categoryIDs)) + transactions
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// purgeCategories hard-deletes the provided categories together with their trashed transactions, which could otherwise
+// only be restored without a category. Live transactions still pointing at them are detached. It returns how many
+// transactions were purged.
+func purgeCategories(tx *gorm.DB, categoryIDs []string) (int64, error) {
+	result := tx.Unscoped().
+		Where("category_id IN ? AND deleted_at IS NOT NULL", categoryIDs).
+		Delete(&models.Transaction{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	if err := tx.Model(&models.Transaction{}).
+		Where("category_id IN ?", categoryIDs).
+		Update("category_id", nil).Error; err != nil {
+		return 0, err
+	}
+
+	if err := tx.Where("category_id IN ?", categoryIDs).Delete(&models.CategoryDeletion{}).Error; err != nil {
+		return 0, err
+	}
+
+	if err := tx.Unscoped().Where("category_id IN ?", categoryIDs).Delete(&models.Category{}).Error; err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected, nil
+}
+
+func findTrashedTransaction(tx *gorm.DB, userID, transactionID string) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := tx.Unscoped().
+		Where("transaction_id = ? AND user_id = ? AND deleted_at IS NOT NULL", transactionID, userID).
+		Take(&transaction).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+func findTrashedCategory(tx *gorm.DB, userID, categoryID string) (*models.Category, error) {
+	var category models.Category
+	if err := tx.Unscoped().
+		Where("category_id = ? AND user_id = ? AND deleted_at IS NOT NULL", categoryID, userID).
+		Take(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &category, nil
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+const (
+	// totpEnrollmentTTL is how long an enrollment waits for its first code before the secret is discarded.
+	totpEnrollmentTTL  = 10 * time.Minute
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 16
+)
+
+// TwoFactorService manages TOTP enrollment and checks second-factor codes.
+type TwoFactorService struct {
+	db     *gorm.DB
+	redis  *redis.Client
+	issuer string
+}
+
+// TwoFactorEnrollment is the secret to load into an authenticator app, also given as an otpauth:// URI.
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// NewTwoFactorService builds a TwoFactorService. The issuer labels the account in authenticator apps.
+func NewTwoFactorService(db *gorm.DB, redis *redis.Client, issuer string) *TwoFactorService {
+	return &TwoFactorService{db: db, redis: redis, issuer: issuer}
+}
+
+// Enroll generates a new secret for the user. It is kept aside until Confirm receives a code generated from it.
+func (s *TwoFactorService) Enroll(ctx context.Context, user *models.User) (*TwoFactorEnrollment, error) {
+	if user.TOTPEnabledAt != nil {
+		return nil, apperror.New(apperror.AuthTwoFactorEnabled, nil)
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redis.Set(ctx, s.enrollmentKey(user.UserID), secret, totpEnrollmentTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorEnrollment{Secret: secret, URI: totpURI(s.issuer, user.Email, secret)}, nil
+}
+
+// Confirm enables two-factor authentication once the code proves the secret was loaded. The recovery codes are
+// returned only here; just their hashes are stored.
+func (s *TwoFactorService) Confirm(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if user.TOTPEnabledAt != nil {
+		return nil, apperror.New(apperror.AuthTwoFactorEnabled, nil)
+	}
+
+	secret, err := s.redis.Get(ctx, s.enrollmentKey(user.UserID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, apperror.New(apperror.AuthTwoFactorDisabled, nil)
+		}
+
+		return nil, err
+	}
+
+	if err := s.checkTOTP(ctx, user.UserID, secret, strings.TrimSpace(code)); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ? AND totp_enabled_at IS NULL", user.UserID).
+		Updates(map[string]interface{}{
+			"totp_secret":         secret,
+			"totp_enabled_at":     time.Now(),
+			"totp_recovery_codes": hashes,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, apperror.New(apperror.AuthTwoFactorEnabled, nil)
+	}
+
+	if err := s.redis.Del(ctx, s.enrollmentKey(user.UserID)).Err(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off after checking a current code. Callers check the password and throttle
+// the attempts (see AuthService.DisableTwoFactor).
+func (s *TwoFactorService) Disable(ctx context.Context, user *models.User, code string) error {
+	if user.TOTPEnabledAt == nil {
+		return apperror.New(apperror.AuthTwoFactorDisabled, nil)
+	}
+
+	if err := s.Verify(ctx, user, code); err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Model(&models.User{}).Where("user_id = ?", user.UserID).
+		Updates(map[string]interface{}{
+			"totp_secret":         "",
+			"totp_enabled_at":     nil,
+			"totp_recovery_codes": models.StringList{},
+		}).Error
+}
+
+// Verify accepts a TOTP code, which cannot be reused, or one of the recovery codes, which is consumed.
+func (s *TwoFactorService) Verify(ctx context.Context, user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		return s.checkTOTP(ctx, user.UserID, user.TOTPSecret, code)
+	}
+
+	hash := hashRecoveryCode(code)
+	idx := slices.Index(user.RecoveryCodes, hash)
+	if idx < 0 {
+		return apperror.New(apperror.AuthTwoFactorInvalid, nil)
+	}
+
+	remaining := slices.Delete(slices.Clone(user.RecoveryCodes), idx, idx+1)
+
+	// Matching the previous list makes concurrent uses of the same code fail.
+	result := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ? AND totp_recovery_codes = ?", user.UserID, user.RecoveryCodes).
+		Update("totp_recovery_codes", models.StringList(remaining))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperror.New(apperror.AuthTwoFactorInvalid, nil)
+	}
+
+	user.RecoveryCodes = remaining
+	return nil
+}
+
+// checkTOTP validates the code and marks its time step as used so an intercepted code cannot be replayed.
+func (s *TwoFactorService) checkTOTP(ctx context.Context, userID, secret, code string) error {
+	counter, ok := validateTOTP(secret, code, time.Now())
+	if !ok {
+		return apperror.New(apperror.AuthTwoFactorInvalid, nil)
+	}
+
+	fresh, err := s.redis.SetNX(ctx, s.usedCodeKey(userID, counter), 1, (2*totpSkew+1)*totpPeriod).Result()
+	if err != nil {
+		return err
+	}
+
+	if !fresh {
+		return apperror.New(apperror.AuthTwoFactorInvalid, nil)
+	}
+
+	return nil
+}
+
+// newRecoveryCodes returns the recovery codes, formatted as xxxx-xxxx-xxxx-xxxx, and their hashes.
+func newRecoveryCodes() ([]string, models.StringList, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make(models.StringList, 0, recoveryCodeCount)
+
+	for len(codes) < recoveryCodeCount {
+		raw := make([]byte, recoveryCodeLength*5/8)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		plain := strings.ToLower(encoding.EncodeToString(raw))
+		groups := make([]string, 0, recoveryCodeLength/4)
+		for i := 0; i < len(plain); i += 4 {
+			groups = append(groups, plain[i:i+4])
+		}
+
+		codes = append(codes, strings.Join(groups, "-"))
+		hashes = append(hashes, hashRecoveryCode(plain))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case, spaces and dashes. The codes carry 80 random bits, so a
+// plain SHA-256 is enough.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *TwoFactorService) enrollmentKey(userID string) string {
+	return fmt.Sprintf("totp-enroll:%s", userID)
+}
+
+func (s *TwoFactorService) usedCodeKey(userID string, counter uint64) string {
+	return fmt.Sprintf("totp-used:%s:%d", userID, counter)
+}
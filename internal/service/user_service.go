@@ -16,8 +16,11 @@ import (
 
 // UserService exposes the operations related to the users table.
 type UserService struct {
-	db        *gorm.DB
-	validator *validator.Validate
+	db            *gorm.DB
+	validator     *validator.Validate
+	categories    *CategoryService
+	defaultPack   string
+	defaultLocale string
 }
 
 // CreateUserInput contains the attributes necessary to create a user.
@@ -26,15 +29,36 @@ type CreateUserInput struct {
 	FirstName string `json:"firstName" validate:"required"`
 	LastName  string `json:"lastName" validate:"required"`
 	Password  string `json:"password" validate:"required,min=6"`
+	Locale    string `json:"locale" validate:"omitempty,oneof=EN ES"`
 }
 
-// NewUserService builds a UserService backed by the provided database handle.
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{db: db, validator: validator.New()}
+// UpdateUserInput carries the profile fields to change; omitted fields are kept.
+type UpdateUserInput struct {
+	Email     *string `json:"email" validate:"omitnil,email"`
+	FirstName *string `json:"firstName" validate:"omitnil,min=1"`
+	LastName  *string `json:"lastName" validate:"omitnil,min=1"`
 }
 
-// Create persists a new user and returns it.
+// DeleteUserInput confirms the account deletion with the user's password.
+type DeleteUserInput struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// NewUserService builds a UserService backed by the provided database handle. New users get the categories of
+// defaultPack named in their locale (or defaultLocale); an empty pack disables the seeding.
+func NewUserService(db *gorm.DB, categories *CategoryService, defaultPack, defaultLocale string) *UserService {
+	return &UserService{
+		db:            db,
+		validator:     validator.New(),
+		categories:    categories,
+		defaultPack:   defaultPack,
+		defaultLocale: defaultLocale,
+	}
+}
+
+// Create persists a new user together with the default categories and returns it.
 func (s *UserService) Create(ctx context.Context, input CreateUserInput) (*models.User, error) {
+	input.Locale = strings.ToUpper(input.Locale)
 	if err := s.validator.Struct(input); err != nil {
 		return nil, apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
 	}
@@ -63,7 +87,25 @@ func (s *UserService) Create(ctx context.Context, input CreateUserInput) (*model
 		Password:  string(hashed),
 	}
 
-	if err := s.db.WithContext(ctx).Create(user).Error; err != nil {
+	locale := input.Locale
+	if locale == "" {
+		locale = s.defaultLocale
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		if s.defaultPack == "" {
+			return nil
+		}
+
+		_, err := s.categories.ApplyTemplate(ctx, tx, user.UserID, s.defaultPack, locale)
+		return err
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
@@ -93,6 +135,111 @@ func (s *UserService) FindByEmail(ctx context.Context, email string) (*models.Us
 	return &user, nil
 }
 
+// setPassword replaces the password hash of the user.
+func (s *UserService) setPassword(ctx context.Context, userID, password string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Model(&models.User{}).Where("user_id = ?", userID).Update("password", string(hashed)).Error
+}
+
+// Update changes the profile of the user. A new email must be verified again, which the caller is told through
+// the returned flag.
+func (s *UserService) Update(ctx context.Context, userID string, input UpdateUserInput) (*models.User, bool, error) {
+	for _, field := range []*string{input.Email, input.FirstName, input.LastName} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+
+	if err := s.validator.Struct(input); err != nil {
+		return nil, false, apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
+	}
+
+	user, err := s.GetByID(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	emailChanged := false
+	updates := map[string]interface{}{}
+	if input.FirstName != nil {
+		updates["first_name"] = *input.FirstName
+	}
+
+	if input.LastName != nil {
+		updates["last_name"] = *input.LastName
+	}
+
+	if input.Email != nil && strings.ToLower(*input.Email) != user.Email {
+		email := strings.ToLower(*input.Email)
+
+		exists, err := s.userExists(ctx, email)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if exists {
+			return nil, false, apperror.New(apperror.UserExists, nil)
+		}
+
+		updates["email"] = email
+		updates["email_verified_at"] = nil
+		emailChanged = true
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.WithContext(ctx).Model(user).Updates(updates).Error; err != nil {
+			return nil, false, err
+		}
+	}
+
+	user, err = s.GetByID(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return user, emailChanged, nil
+}
+
+// Delete permanently removes the user and everything they own in a single transaction once the password is confirmed.
+// Sessions live in Redis and are ended by the caller.
+func (s *UserService) Delete(ctx context.Context, userID string, input DeleteUserInput) error {
+	if err := s.validator.Struct(input); err != nil {
+		return apperror.New(apperror.ServerParamsMissing, formatValidationErrors(err))
+	}
+
+	user, err := s.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)) != nil {
+		return apperror.New(apperror.AuthBadAuth, nil)
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Children first, so the deletion also works where foreign keys are enforced.
+		for _, model := range []interface{}{
+			&models.Transaction{},
+			&models.CategoryDeletion{},
+			&models.Category{},
+			&models.NetWorthSnapshot{},
+			&models.APIToken{},
+			&models.UserIdentity{},
+			&models.User{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 // GetByID fetches the user that owns the provided identifier.
 func (s *UserService) GetByID(ctx context.Context, userID string) (*models.User, error) {
 	var user models.User
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/iperez/new-expenses-go/internal/domain/models"
+	"github.com/iperez/new-expenses-go/internal/mail"
+	"github.com/iperez/new-expenses-go/pkg/apperror"
+)
+
+// verificationResendInterval is the minimum time between two verification emails to the same user.
+const verificationResendInterval = time.Minute
+
+// VerificationService confirms that users own their email address.
+type VerificationService struct {
+	db     *gorm.DB
+	redis  *redis.Client
+	mailer mail.Mailer
+	ttl    time.Duration
+	appURL string
+}
+
+// NewVerificationService builds a VerificationService. Links point to appURL and stay valid for ttl.
+func NewVerificationService(db *gorm.DB, redis *redis.Client, mailer mail.Mailer, ttl time.Duration, appURL string) *VerificationService {
+	return &VerificationService{db: db, redis: redis, mailer: mailer, ttl: ttl, appURL: appURL}
+}
+
+// Send emails a verification link to the user, at most once per verificationResendInterval and address. Verified
+// users are skipped. The token is bound to the current email, so changing it invalidates the links already sent.
+func (s *VerificationService) Send(ctx context.Context, user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	allowed, err := s.redis.SetNX(ctx, s.throttleKey(user.UserID, user.Email), 1, verificationResendInterval).Result()
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return apperror.New(apperror.UserVerificationThrottled, nil)
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	if err := s.redis.Set(ctx, s.tokenKey(token), user.UserID+":"+user.Email, s.ttl).Err(); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.appURL, url.QueryEscape(token))
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the following link. It expires in %d hours.\n\n%s\n",
+			user.FirstName, int(s.ttl.Hours()), link),
+	})
+}
+
+// Verify consumes a verification token and marks the email it was sent to as verified.
+func (s *VerificationService) Verify(ctx context.Context, token string) error {
+	value, err := s.redis.GetDel(ctx, s.tokenKey(token)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return apperror.New(apperror.UserVerificationInvalid, nil)
+		}
+
+		return err
+	}
+
+	userID, email, _ := strings.Cut(value, ":")
+
+	result := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ? AND email = ?", userID, email).
+		Update("email_verified_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperror.New(apperror.UserVerificationInvalid, nil)
+	}
+
+	return nil
+}
+
+func (s *VerificationService) tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("email-verify:%s", hex.EncodeToString(sum[:]))
+}
+
+func (s *VerificationService) throttleKey(userID, email string) string {
+	return fmt.Sprintf("email-verify-sent:%s:%s", userID, email)
+}
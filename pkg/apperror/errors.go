@@ -1,6 +1,7 @@
 package apperror
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 )
@@ -10,21 +11,43 @@ type Code int
 
 const (
 	// Auth errors.
-	AuthBadAuth   Code = 1002
-	AuthNeedLogin Code = 1005
+	AuthBadAuth           Code = 1002
+	AuthNeedLogin         Code = 1005
+	AuthSessionNotFound   Code = 1006
+	AuthTokenInvalid      Code = 1007
+	AuthTokenScope        Code = 1008
+	AuthSessionRequired   Code = 1009
+	AuthTokenNotFound     Code = 1010
+	AuthResetInvalid      Code = 1011
+	AuthEmailUnverified   Code = 1012
+	AuthLocked            Code = 1013
+	AuthTwoFactorInvalid  Code = 1014
+	AuthTwoFactorEnabled  Code = 1015
+	AuthTwoFactorDisabled Code = 1016
+	AuthOIDCInvalid       Code = 1017
+	AuthOIDCUnlinked      Code = 1018
 	// Server errors.
 	ServerTooFewParams  Code = 2001
 	ServerParamsMissing Code = 2002
 	ServerNotFound      Code = 2003
 	// User errors.
-	UserNotFound Code = 3001
-	UserExists   Code = 3004
+	UserNotFound              Code = 3001
+	UserExists                Code = 3004
+	UserVerificationInvalid   Code = 3005
+	UserVerificationThrottled Code = 3006
 	// Transaction errors.
 	TransactionNotFound             Code = 4001
 	TransactionCategoryTypeMismatch Code = 4002
 	// Category errors.
-	CategoryNotFound        Code = 5001
-	CategoryHasTransactions Code = 5002
+	CategoryNotFound          Code = 5001
+	CategoryHasTransactions   Code = 5002
+	CategoryUndoUnavailable   Code = 5003
+	CategoryMergeTypeMismatch Code = 5004
+	CategoryExists            Code = 5005
+	CategoryParentInvalid     Code = 5006
+	CategoryTemplateNotFound  Code = 5007
+	// Trash errors.
+	TrashItemNotFound Code = 6001
 )
 
 // Definition holds the metadata returned alongside an error response.
@@ -51,6 +74,110 @@ var registry = map[Code]Definition{
 		},
 		HTTPStatus: http.StatusUnauthorized,
 	},
+	AuthSessionNotFound: {
+		Message: "Session not found",
+		ShowMessage: map[string]string{
+			"EN": "The session does not exist or has already ended",
+			"ES": "La sesión no existe o ya finalizó",
+		},
+		HTTPStatus: http.StatusNotFound,
+	},
+	AuthTokenInvalid: {
+		Message: "Invalid API token",
+		ShowMessage: map[string]string{
+			"EN": "The API token is invalid or has expired",
+			"ES": "El token de API no es válido o expiró",
+		},
+		HTTPStatus: http.StatusUnauthorized,
+	},
+	AuthTokenScope: {
+		Message: "API token scope does not allow this request",
+		ShowMessage: map[string]string{
+			"EN": "This API token is read-only",
+			"ES": "Este token de API es de solo lectura",
+		},
+		HTTPStatus: http.StatusForbidden,
+	},
+	AuthSessionRequired: {
+		Message: "A browser session is required",
+		ShowMessage: map[string]string{
+			"EN": "Log in with your email and password to perform this action",
+			"ES": "Inicie sesión con su email y contraseña para realizar esta acción",
+		},
+		HTTPStatus: http.StatusForbidden,
+	},
+	AuthTokenNotFound: {
+		Message: "API token not found",
+		ShowMessage: map[string]string{
+			"EN": "The API token does not exist",
+			"ES": "El token de API no existe",
+		},
+		HTTPStatus: http.StatusNotFound,
+	},
+	AuthResetInvalid: {
+		Message: "Invalid password reset token",
+		ShowMessage: map[string]string{
+			"EN": "The password reset link is invalid or has expired",
+			"ES": "El enlace para restablecer la contraseña no es válido o expiró",
+		},
+		HTTPStatus: http.StatusBadRequest,
+	},
+	AuthEmailUnverified: {
+		Message: "Email not verified",
+		ShowMessage: map[string]string{
+			"EN": "Verify your email address before logging in",
+			"ES": "Verifique su dirección de email antes de iniciar sesión",
+		},
+		HTTPStatus: http.StatusForbidden,
+	},
+	AuthLocked: {
+		Message: "Too many failed login attempts",
+		ShowMessage: map[string]string{
+			"EN": "Too many failed login attempts, please try again later",
+			"ES": "Demasiados intentos fallidos, intente nuevamente más tarde",
+		},
+		HTTPStatus: http.StatusTooManyRequests,
+	},
+	AuthTwoFactorInvalid: {
+		Message: "Invalid two-factor code",
+		ShowMessage: map[string]string{
+			"EN": "The verification code is invalid or has already been used",
+			"ES": "El código de verificación no es válido o ya fue utilizado",
+		},
+		HTTPStatus: http.StatusBadRequest,
+	},
+	AuthTwoFactorEnabled: {
+		Message: "Two-factor authentication already enabled",
+		ShowMessage: map[string]string{
+			"EN": "Two-factor authentication is already enabled",
+			"ES": "La autenticación en dos pasos ya está activada",
+		},
+		HTTPStatus: http.StatusConflict,
+	},
+	AuthTwoFactorDisabled: {
+		Message: "Two-factor authentication not enabled",
+		ShowMessage: map[string]string{
+			"EN": "Two-factor authentication is not enabled or the enrollment expired",
+			"ES": "La autenticación en dos pasos no está activada o la activación expiró",
+		},
+		HTTPStatus: http.StatusConflict,
+	},
+	AuthOIDCInvalid: {
+		Message: "Single sign-on failed",
+		ShowMessage: map[string]string{
+			"EN": "Single sign-on failed or expired, please try again",
+			"ES": "El inicio de sesión único falló o expiró, intente nuevamente",
+		},
+		HTTPStatus: http.StatusBadRequest,
+	},
+	AuthOIDCUnlinked: {
+		Message: "No account for the identity provider email",
+		ShowMessage: map[string]string{
+			"EN": "There is no account with the verified email of your identity provider",
+			"ES": "No existe una cuenta con el email verificado de su proveedor de identidad",
+		},
+		HTTPStatus: http.StatusForbidden,
+	},
 	ServerTooFewParams: {
 		Message: "Too few parameters",
 		ShowMessage: map[string]string{
@@ -91,6 +218,22 @@ var registry = map[Code]Definition{
 		},
 		HTTPStatus: http.StatusConflict,
 	},
+	UserVerificationInvalid: {
+		Message: "Invalid email verification token",
+		ShowMessage: map[string]string{
+			"EN": "The verification link is invalid or has expired",
+			"ES": "El enlace de verificación no es válido o expiró",
+		},
+		HTTPStatus: http.StatusBadRequest,
+	},
+	UserVerificationThrottled: {
+		Message: "Verification email recently sent",
+		ShowMessage: map[string]string{
+			"EN": "We just sent you a verification email, please wait a minute before asking again",
+			"ES": "Acabamos de enviarle un email de verificación, espere un minuto antes de pedir otro",
+		},
+		HTTPStatus: http.StatusTooManyRequests,
+	},
 	TransactionNotFound: {
 		Message: "Transaction not exist",
 		ShowMessage: map[string]string{
@@ -123,6 +266,54 @@ var registry = map[Code]Definition{
 		},
 		HTTPStatus: http.StatusBadRequest,
 	},
+	CategoryUndoUnavailable: {
+		Message: "Category deletion can no longer be undone",
+		ShowMessage: map[string]string{
+			"EN": "There is no recent deletion of this category to undo.",
+			"ES": "No hay una eliminación reciente de esta categoría para deshacer.",
+		},
+		HTTPStatus: http.StatusConflict,
+	},
+	CategoryMergeTypeMismatch: {
+		Message: "Only categories of the same type can be merged",
+		ShowMessage: map[string]string{
+			"EN": "Only categories of the same type can be merged.",
+			"ES": "Solo se pueden combinar categorías del mismo tipo.",
+		},
+		HTTPStatus: http.StatusConflict,
+	},
+	CategoryExists: {
+		Message: "Category already exists",
+		ShowMessage: map[string]string{
+			"EN": "A category with this name and type already exists",
+			"ES": "Ya existe una categoría con este nombre y tipo",
+		},
+		HTTPStatus: http.StatusConflict,
+	},
+	CategoryParentInvalid: {
+		Message: "Invalid parent category",
+		ShowMessage: map[string]string{
+			"EN": "The parent category must have the same type and cannot be the category itself or one of its subcategories.",
+			"ES": "La categoría padre debe ser del mismo tipo y no puede ser la propia categoría ni una de sus subcategorías.",
+		},
+		HTTPStatus: http.StatusConflict,
+	},
+	CategoryTemplateNotFound: {
+		Message: "Category template not exist",
+		ShowMessage: map[string]string{
+			"EN": "Category template not exist",
+			"ES": "La plantilla de categorías no existe",
+		},
+		HTTPStatus: http.StatusNotFound,
+	},
+	TrashItemNotFound: {
+		Message: "Item not found in trash",
+		ShowMessage: map[string]string{
+			"EN": "The item does not exist in the trash",
+			"ES": "El elemento no existe en la papelera",
+		},
+		HTTPStatus: http.StatusNotFound,
+	},
 }
 
 // AppError implements the Go error interface with custom metadata.
@@ -148,6 +339,12 @@ func New(code Code, data interface{}) AppError {
 	}
 }
 
+// Is reports whether err is an AppError with the provided code.
+func Is(err error, code Code) bool {
+	var appErr AppError
+	return errors.As(err, &appErr) && appErr.Code == code
+}
+
 // Lookup returns the metadata associated with the provided code.
 func Lookup(code Code) Definition {
 	if def, ok := registry[code]; ok {